@@ -0,0 +1,193 @@
+package baseline
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"go.bbkane.com/shovel/dig"
+	"gopkg.in/yaml.v3"
+)
+
+// FormatVersion is bumped on incompatible changes to the baseline file format
+const FormatVersion = 1
+
+// Key identifies a combination across runs
+type Key struct {
+	Qname      string `yaml:"qname"`
+	Rtype      string `yaml:"rtype"`
+	Subnet     string `yaml:"subnet"`
+	Nameserver string `yaml:"nameserver"`
+}
+
+func (k Key) String() string {
+	parts := []string{k.Qname, k.Rtype}
+	if k.Subnet != "" {
+		parts = append(parts, k.Subnet)
+	}
+	parts = append(parts, k.Nameserver)
+	return strings.Join(parts, " ")
+}
+
+type Answer struct {
+	Content []string `yaml:"content"`
+	Count   int      `yaml:"count"`
+}
+
+// Error counts the errors in a category. See dig.ClassifyError
+type Error struct {
+	Category string   `yaml:"category"`
+	Count    int      `yaml:"count"`
+	Samples  []string `yaml:"samples,omitempty"`
+}
+
+// Tsig names the key a combination was signed with. The secret isn't saved
+type Tsig struct {
+	Name      string `yaml:"name"`
+	Algorithm string `yaml:"algorithm"`
+}
+
+// Combination is the params and results of one dig combination
+type Combination struct {
+	Key            Key           `yaml:"key"`
+	Proto          string        `yaml:"proto"`
+	Count          int           `yaml:"count"`
+	Timeout        time.Duration `yaml:"timeout,omitempty"`
+	Tsig           *Tsig         `yaml:"tsig,omitempty"`
+	NoRecurse      bool          `yaml:"no_recurse,omitempty"`
+	OrderedAnswers bool          `yaml:"ordered_answers,omitempty"`
+	Answers        []Answer      `yaml:"answers"`
+	Errors         []Error       `yaml:"errors"`
+}
+
+// File is a saved baseline
+type File struct {
+	Version       int           `yaml:"version"`
+	Created       time.Time     `yaml:"created"`
+	ShovelVersion string        `yaml:"shovel_version"`
+	Combinations  []Combination `yaml:"combinations"`
+}
+
+// KeyFor returns the key of a combination. Subnet is empty if there's no client subnet
+func KeyFor(p dig.DigOneParams) Key {
+	subnet := ""
+	if p.SubnetIP != nil {
+		subnet = dig.SubnetString(p.SubnetIP, p.SubnetBits)
+	}
+	return Key{
+		Qname:      p.Qname,
+		Rtype:      dns.TypeToString[p.Rtype],
+		Subnet:     subnet,
+		Nameserver: p.NameserverIPPort,
+	}
+}
+
+// Params rebuilds the dig params of each combination so the baseline's matrix can be dug again.
+// Signed combinations get a TsigKey without a Secret, which must be filled in before digging
+func (f File) Params() ([]dig.DigRepeatParams, error) {
+	ret := make([]dig.DigRepeatParams, 0, len(f.Combinations))
+	for _, c := range f.Combinations {
+		rtype, exists := dns.StringToType[c.Key.Rtype]
+		if !exists {
+			return nil, fmt.Errorf("unknown rtype in baseline combination %s: %s", c.Key, c.Key.Rtype)
+		}
+		p := dig.EmptyDigOneparams()
+		p.NameserverIPPort = c.Key.Nameserver
+		p.Proto = c.Proto
+		p.Qname = c.Key.Qname
+		p.Rtype = rtype
+		p.Timeout = c.Timeout
+		p.NoRecurse = c.NoRecurse
+		p.OrderedAnswers = c.OrderedAnswers
+		if c.Tsig != nil {
+			p.Tsig = &dig.TsigKey{Name: c.Tsig.Name, Algorithm: c.Tsig.Algorithm, Secret: ""}
+		}
+		switch {
+		case c.Key.Subnet == "":
+			// no client subnet
+		case strings.Contains(c.Key.Subnet, "/"):
+			prefix, err := netip.ParsePrefix(c.Key.Subnet)
+			if err != nil {
+				return nil, fmt.Errorf("invalid subnet in baseline combination %s: %w", c.Key, err)
+			}
+			p.SubnetIP = net.IP(prefix.Addr().AsSlice())
+			p.SubnetBits = prefix.Bits()
+		default:
+			p.SubnetIP = net.ParseIP(c.Key.Subnet)
+			if p.SubnetIP == nil {
+				return nil, fmt.Errorf("invalid subnet in baseline combination %s: %s", c.Key, c.Key.Subnet)
+			}
+		}
+		ret = append(ret, dig.DigRepeatParams{DigOneParams: p, Count: c.Count, Interval: 0})
+	}
+	return ret, nil
+}
+
+// New builds a baseline from params and their results
+func New(created time.Time, shovelVersion string, params []dig.DigRepeatParams, results []dig.DigRepeatResult) File {
+	f := File{
+		Version:       FormatVersion,
+		Created:       created,
+		ShovelVersion: shovelVersion,
+		Combinations:  make([]Combination, 0, len(params)),
+	}
+	for i, p := range params {
+		c := Combination{
+			Key:            KeyFor(p.DigOneParams),
+			Proto:          p.DigOneParams.Proto,
+			Count:          p.Count,
+			Timeout:        p.DigOneParams.Timeout,
+			Tsig:           nil,
+			NoRecurse:      p.DigOneParams.NoRecurse,
+			OrderedAnswers: p.DigOneParams.OrderedAnswers,
+			Answers:        make([]Answer, 0, len(results[i].Answers)),
+			Errors:         make([]Error, 0, len(results[i].Errors)),
+		}
+		if p.DigOneParams.Tsig != nil {
+			c.Tsig = &Tsig{Name: p.DigOneParams.Tsig.Name, Algorithm: p.DigOneParams.Tsig.Algorithm}
+		}
+		for _, a := range results[i].Answers {
+			c.Answers = append(c.Answers, Answer{Content: a.StringSlice, Count: a.Count})
+		}
+		for _, e := range results[i].Errors {
+			c.Errors = append(c.Errors, Error{Category: string(e.Category), Count: e.Count, Samples: e.Samples})
+		}
+		f.Combinations = append(f.Combinations, c)
+	}
+	return f
+}
+
+// Save a baseline as YAML
+func Save(path string, f File) error {
+	b := bytes.Buffer{}
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&f); err != nil {
+		return fmt.Errorf("could not serialize baseline: %w", err)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("could not write baseline: %w", err)
+	}
+	return nil
+}
+
+// Load a baseline, checking its version
+func Load(path string) (*File, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read baseline: %w", err)
+	}
+	var f File
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("could not parse baseline: %s: %w", path, err)
+	}
+	if f.Version != FormatVersion {
+		return nil, fmt.Errorf("unsupported baseline version %d in %s. This version of shovel reads version %d", f.Version, path, FormatVersion)
+	}
+	return &f, nil
+}
@@ -0,0 +1,159 @@
+package baseline
+
+import (
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.bbkane.com/shovel/counter"
+	"go.bbkane.com/shovel/dig"
+)
+
+func newParams(qname string, subnet net.IP, nameserver string) dig.DigRepeatParams {
+	p := dig.EmptyDigOneparams()
+	p.Qname = qname
+	p.Rtype = 1
+	p.Proto = "udp"
+	p.SubnetIP = subnet
+	p.NameserverIPPort = nameserver
+	return dig.DigRepeatParams{DigOneParams: p, Count: 10, Interval: 0}
+}
+
+func newResult(answers []counter.StringSliceCount, errs []dig.ErrorCount) dig.DigRepeatResult {
+	return dig.DigRepeatResult{
+		Answers:         answers,
+		AnswerTTLs:      nil,
+		Errors:          errs,
+		Latency:         dig.LatencyStats{Min: 0, P50: 0, P90: 0, P99: 0, Max: 0},
+		AnswersOverTime: nil,
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := New(
+		created,
+		"v1.0.0",
+		[]dig.DigRepeatParams{newParams("www.example.com", net.ParseIP("101.251.8.0"), "8.8.8.8:53")},
+		[]dig.DigRepeatResult{newResult(
+			[]counter.StringSliceCount{{StringSlice: []string{"1.1.1.1", "2.2.2.2"}, Count: 9}},
+			[]dig.ErrorCount{{Category: dig.ErrorTimeout, Count: 1, Samples: []string{"exchange err: read udp 8.8.8.8:53: i/o timeout"}}},
+		)},
+	)
+
+	path := filepath.Join(t.TempDir(), "baseline.yaml")
+	require.Nil(t, Save(path, f))
+
+	loaded, err := Load(path)
+	require.Nil(t, err)
+	require.Equal(t, f, *loaded)
+	require.Equal(t, Key{Qname: "www.example.com", Rtype: "A", Subnet: "101.251.8.0", Nameserver: "8.8.8.8:53"}, loaded.Combinations[0].Key)
+
+	require.Nil(t, os.WriteFile(path, []byte("version: 99\n"), 0o600))
+	_, err = Load(path)
+	require.ErrorContains(t, err, "unsupported baseline version 99")
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	base := New(
+		created,
+		"v1.0.0",
+		[]dig.DigRepeatParams{
+			newParams("www.example.com", nil, "8.8.8.8:53"),
+			newParams("old.example.com", nil, "8.8.8.8:53"),
+		},
+		[]dig.DigRepeatResult{
+			newResult(
+				[]counter.StringSliceCount{
+					{StringSlice: []string{"1.1.1.1"}, Count: 5},
+					{StringSlice: []string{"2.2.2.2"}, Count: 5},
+				},
+				nil,
+			),
+			newResult([]counter.StringSliceCount{{StringSlice: []string{"3.3.3.3"}, Count: 10}}, nil),
+		},
+	)
+	current := New(
+		created,
+		"v1.0.0",
+		[]dig.DigRepeatParams{
+			newParams("www.example.com", nil, "8.8.8.8:53"),
+			newParams("new.example.com", nil, "8.8.8.8:53"),
+		},
+		[]dig.DigRepeatResult{
+			newResult(
+				[]counter.StringSliceCount{
+					{StringSlice: []string{"1.1.1.1"}, Count: 8},
+					{StringSlice: []string{"4.4.4.4"}, Count: 1},
+				},
+				[]dig.ErrorCount{{Category: dig.ErrorTimeout, Count: 1, Samples: []string{"exchange err: read udp 8.8.8.8:53: i/o timeout"}}},
+			),
+			newResult([]counter.StringSliceCount{{StringSlice: []string{"3.3.3.3"}, Count: 10}}, nil),
+		},
+	)
+
+	www := Key{Qname: "www.example.com", Rtype: "A", Subnet: "", Nameserver: "8.8.8.8:53"}
+	require.Equal(
+		t,
+		[]Change{
+			{Key: www, Kind: Frequency, Entry: "1.1.1.1", Before: "50.0% (5)", After: "80.0% (8)"},
+			{Key: www, Kind: Disappeared, Entry: "2.2.2.2", Before: "50.0% (5)", After: ""},
+			{Key: www, Kind: Appeared, Entry: "4.4.4.4", Before: "", After: "10.0% (1)"},
+			{Key: www, Kind: Appeared, Entry: "error: timeout", Before: "", After: "10.0% (1)"},
+			{Key: Key{Qname: "new.example.com", Rtype: "A", Subnet: "", Nameserver: "8.8.8.8:53"}, Kind: NewCombination, Entry: "", Before: "", After: ""},
+			{Key: Key{Qname: "old.example.com", Rtype: "A", Subnet: "", Nameserver: "8.8.8.8:53"}, Kind: MissingCombination, Entry: "", Before: "", After: ""},
+		},
+		Compare(base, current, 10),
+	)
+
+	// within tolerance
+	require.Len(t, Compare(base, current, 40), 5)
+}
+
+func TestParams(t *testing.T) {
+	t.Parallel()
+
+	prefixParams := newParams("www.example.com", net.IP(netip.MustParseAddr("101.251.8.0").AsSlice()), "8.8.8.8:53")
+	prefixParams.DigOneParams.SubnetBits = 24
+	snoopParams := newParams("{{rand}}.example.com", nil, "8.8.8.8:53")
+	snoopParams.DigOneParams.NoRecurse = true
+	snoopParams.DigOneParams.OrderedAnswers = true
+	snoopParams.DigOneParams.Timeout = time.Second
+	signedParams := newParams("www.example.com", nil, "8.8.8.8:53")
+	signedParams.DigOneParams.Tsig = &dig.TsigKey{Name: "key.", Algorithm: "hmac-sha256.", Secret: "c2VjcmV0"}
+	params := []dig.DigRepeatParams{
+		newParams("www.example.com", nil, "8.8.8.8:53"),
+		newParams("www.example.com", net.ParseIP("101.251.8.1"), "8.8.8.8:53"),
+		prefixParams,
+		snoopParams,
+		signedParams,
+	}
+	results := make([]dig.DigRepeatResult, len(params))
+	for i := range results {
+		results[i] = newResult(nil, nil)
+	}
+
+	path := filepath.Join(t.TempDir(), "baseline.yaml")
+	require.Nil(t, Save(path, New(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "v1.0.0", params, results)))
+	f, err := Load(path)
+	require.Nil(t, err)
+	actual, err := f.Params()
+	require.Nil(t, err)
+
+	// the secret isn't saved
+	params[4].DigOneParams.Tsig = &dig.TsigKey{Name: "key.", Algorithm: "hmac-sha256.", Secret: ""}
+	require.Equal(t, params, actual)
+
+	f.Combinations[0].Key.Rtype = "NOPE"
+	_, err = f.Params()
+	require.NotNil(t, err)
+}
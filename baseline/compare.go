@@ -0,0 +1,128 @@
+package baseline
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+type ChangeKind string
+
+const (
+	// Appeared answers or errors weren't in the baseline
+	Appeared ChangeKind = "appeared"
+	// Disappeared answers or errors were only in the baseline
+	Disappeared ChangeKind = "disappeared"
+	// Frequency changed by more than the tolerance
+	Frequency ChangeKind = "frequency changed"
+	// NewCombination wasn't dug for the baseline
+	NewCombination ChangeKind = "new combination"
+	// MissingCombination was in the baseline but wasn't dug this time
+	MissingCombination ChangeKind = "missing combination"
+)
+
+// Change between a baseline and the current results for one combination
+type Change struct {
+	Key  Key
+	Kind ChangeKind
+	// Entry is the answers (one per line) or "error: <msg>". Empty for new and missing combinations
+	Entry  string
+	Before string
+	After  string
+}
+
+// entryShares returns the percentage of digs for each distinct answer set or error of a combination
+func entryShares(c Combination) (map[string]float64, map[string]int) {
+	counts := make(map[string]int)
+	total := 0
+	for _, a := range c.Answers {
+		counts[strings.Join(a.Content, "\n")] += a.Count
+		total += a.Count
+	}
+	for _, e := range c.Errors {
+		// compare categories, as sample messages vary between runs
+		counts["error: "+e.Category] += e.Count
+		total += e.Count
+	}
+	shares := make(map[string]float64)
+	for entry, count := range counts {
+		shares[entry] = 100 * float64(count) / float64(total)
+	}
+	return shares, counts
+}
+
+func fmtShare(share float64, count int) string {
+	return fmt.Sprintf("%.1f%% (%d)", share, count)
+}
+
+// Compare current results to a baseline. Combinations are matched by Key.
+// A frequency change is reported when an entry's share of digs moves by more than tolerance percentage points.
+// Changes are ordered by the current combinations, then combinations missing from the current run.
+func Compare(base File, current File, tolerance float64) []Change {
+	baseByKey := make(map[Key]Combination)
+	for _, c := range base.Combinations {
+		baseByKey[c.Key] = c
+	}
+
+	changes := []Change{}
+	seen := make(map[Key]bool)
+	for _, cur := range current.Combinations {
+		seen[cur.Key] = true
+		old, exists := baseByKey[cur.Key]
+		if !exists {
+			changes = append(changes, Change{Key: cur.Key, Kind: NewCombination, Entry: "", Before: "", After: ""})
+			continue
+		}
+
+		oldShares, oldCounts := entryShares(old)
+		curShares, curCounts := entryShares(cur)
+
+		entries := []string{}
+		for entry := range oldShares {
+			entries = append(entries, entry)
+		}
+		for entry := range curShares {
+			if _, exists := oldShares[entry]; !exists {
+				entries = append(entries, entry)
+			}
+		}
+		sort.Strings(entries)
+
+		for _, entry := range entries {
+			oldShare, inOld := oldShares[entry]
+			curShare, inCur := curShares[entry]
+			change := Change{
+				Key:    cur.Key,
+				Kind:   "",
+				Entry:  entry,
+				Before: "",
+				After:  "",
+			}
+			if inOld {
+				change.Before = fmtShare(oldShare, oldCounts[entry])
+			}
+			if inCur {
+				change.After = fmtShare(curShare, curCounts[entry])
+			}
+			switch {
+			case !inOld:
+				change.Kind = Appeared
+			case !inCur:
+				change.Kind = Disappeared
+			case math.Abs(curShare-oldShare) > tolerance:
+				change.Kind = Frequency
+			default:
+				continue
+			}
+			changes = append(changes, change)
+		}
+	}
+
+	for _, old := range base.Combinations {
+		if !seen[old.Key] {
+			changes = append(changes, Change{Key: old.Key, Kind: MissingCombination, Entry: "", Before: "", After: ""})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,204 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.bbkane.com/shovel/counter"
+	"go.bbkane.com/shovel/dig"
+)
+
+// WeightedQname is a qname and how often to query it relative to the others
+type WeightedQname struct {
+	Qname  string
+	Weight float64
+}
+
+// ParseWeightedQnames parses qnames with an optional =weight suffix. Example: www.example.com=70 . The default weight is 1
+func ParseWeightedQnames(strs []string) ([]WeightedQname, error) {
+	ret := []WeightedQname{}
+	for _, s := range strs {
+		qname, weightStr, found := strings.Cut(s, "=")
+		weight := 1.0
+		if found {
+			var err error
+			weight, err = strconv.ParseFloat(weightStr, 64)
+			if err != nil || weight <= 0 {
+				return nil, fmt.Errorf("weight must be a positive number: %s", s)
+			}
+		}
+		if err := dig.ValidateQnameTemplate(qname); err != nil {
+			return nil, err
+		}
+		ret = append(ret, WeightedQname{Qname: qname, Weight: weight})
+	}
+	if len(ret) == 0 {
+		return nil, errors.New("no qnames passed")
+	}
+	return ret, nil
+}
+
+// pickQname draws a qname by weight
+func pickQname(qnames []WeightedQname, total float64) string {
+	r := rand.Float64() * total //nolint:gosec // load distribution, not security
+	for _, q := range qnames {
+		if r < q.Weight {
+			return q.Qname
+		}
+		r -= q.Weight
+	}
+	return qnames[len(qnames)-1].Qname
+}
+
+// Params for a benchmark
+type Params struct {
+	// DigOneParams holds the settings shared by every query: Proto, Rtype, subnet and Timeout.
+	// Qname and NameserverIPPort are filled in per query
+	DigOneParams dig.DigOneParams
+	// Nameservers to spread queries across uniformly
+	Nameservers []string
+	Qnames      []WeightedQname
+	// QPS to send at. 0 runs closed loop: each of Workers sends its next query when the last one returns
+	QPS float64
+	// Workers is the number of closed loop workers, or the maximum queries in flight when sending at QPS
+	Workers  int
+	Duration time.Duration
+	// Interval to group results into over time
+	Interval time.Duration
+}
+
+// Classify a result as NOERROR or its error category. See dig.ClassifyError
+func Classify(res dig.DigOneResult) string {
+	if res.Err == nil {
+		return "NOERROR"
+	}
+	return string(dig.ClassifyError(res.Err))
+}
+
+type intervalAgg struct {
+	sent     int
+	timeouts int
+	rtts     []time.Duration
+}
+
+type collector struct {
+	mu        sync.Mutex
+	start     time.Time
+	interval  time.Duration
+	intervals []intervalAgg
+	outcomes  counter.StringCounter
+	rtts      []time.Duration
+	dropped   int
+}
+
+func (c *collector) add(sentAt time.Time, res dig.DigOneResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	idx := int(sentAt.Sub(c.start) / c.interval)
+	for len(c.intervals) <= idx {
+		c.intervals = append(c.intervals, intervalAgg{sent: 0, timeouts: 0, rtts: nil})
+	}
+	outcome := Classify(res)
+	c.outcomes.Add(outcome)
+	c.intervals[idx].sent++
+	if outcome == "timeout" {
+		c.intervals[idx].timeouts++
+	}
+	if res.RTT > 0 {
+		c.rtts = append(c.rtts, res.RTT)
+		c.intervals[idx].rtts = append(c.intervals[idx].rtts, res.RTT)
+	}
+}
+
+func (c *collector) drop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dropped++
+}
+
+// Bench sends queries for the duration and reports on them. Queries still in flight when the duration ends aren't counted
+func Bench(ctx context.Context, digOne dig.DigOneFunc, p Params) Report {
+	ctx, cancel := context.WithTimeout(ctx, p.Duration)
+	defer cancel()
+
+	totalWeight := 0.0
+	for _, q := range p.Qnames {
+		totalWeight += q.Weight
+	}
+
+	c := &collector{
+		mu:        sync.Mutex{},
+		start:     time.Now(),
+		interval:  p.Interval,
+		intervals: []intervalAgg{},
+		outcomes:  counter.NewStringCounter(),
+		rtts:      []time.Duration{},
+		dropped:   0,
+	}
+
+	query := func() {
+		digOneParams := p.DigOneParams
+		digOneParams.Qname = dig.ExpandQnameTemplate(pickQname(p.Qnames, totalWeight))
+		digOneParams.NameserverIPPort = p.Nameservers[rand.IntN(len(p.Nameservers))] //nolint:gosec // load distribution, not security
+		sentAt := time.Now()
+		res := digOne(ctx, digOneParams)
+		if ctx.Err() != nil {
+			// cut off by the end of the run
+			return
+		}
+		c.add(sentAt, res)
+	}
+
+	wg := sync.WaitGroup{}
+	if p.QPS > 0 {
+		// Send however many queries are due on each tick so the rate holds even when the ticker can't tick once per query
+		tick := max(time.Duration(float64(time.Second)/p.QPS), time.Millisecond)
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		inFlight := make(chan struct{}, p.Workers)
+		scheduled := 0
+	loop:
+		for {
+			select {
+			case <-ctx.Done():
+				break loop
+			case now := <-ticker.C:
+				due := int(now.Sub(c.start).Seconds()*p.QPS) - scheduled
+				for range due {
+					scheduled++
+					select {
+					case inFlight <- struct{}{}:
+						wg.Add(1)
+						go func() {
+							defer wg.Done()
+							defer func() { <-inFlight }()
+							query()
+						}()
+					default:
+						c.drop()
+					}
+				}
+			}
+		}
+	} else {
+		for range p.Workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for ctx.Err() == nil {
+					query()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	return newReport(c, time.Since(c.start))
+}
@@ -0,0 +1,101 @@
+package bench
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+	"go.bbkane.com/shovel/dig"
+)
+
+func TestParseWeightedQnames(t *testing.T) {
+	t.Parallel()
+
+	actual, err := ParseWeightedQnames([]string{"www.example.com=70", "{{rand}}.example.com"})
+	require.Nil(t, err)
+	require.Equal(t, []WeightedQname{{Qname: "www.example.com", Weight: 70}, {Qname: "{{rand}}.example.com", Weight: 1}}, actual)
+
+	_, err = ParseWeightedQnames([]string{"www.example.com=-1"})
+	require.NotNil(t, err)
+
+	_, err = ParseWeightedQnames([]string{"{{nope}}.example.com"})
+	require.NotNil(t, err)
+}
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "NOERROR", Classify(dig.DigOneResult{Answers: []string{"1.1.1.1"}, TTLs: nil, RTT: 0, Err: nil}))
+	require.Equal(t, "NXDOMAIN", Classify(dig.DigOneResult{Answers: nil, TTLs: nil, RTT: 0, Err: dig.RcodeError(dns.RcodeNameError)}))
+	require.Equal(t, "NODATA", Classify(dig.DigOneResult{Answers: nil, TTLs: nil, RTT: 0, Err: dig.ErrNoAnswers}))
+	require.Equal(t, "timeout", Classify(dig.DigOneResult{Answers: nil, TTLs: nil, RTT: 0, Err: context.DeadlineExceeded}))
+	require.Equal(t, "SERVFAIL", Classify(dig.DigOneResult{Answers: nil, TTLs: nil, RTT: 0, Err: dig.RcodeError(dns.RcodeServerFailure)}))
+}
+
+func TestNewHistogram(t *testing.T) {
+	t.Parallel()
+
+	bins := newHistogram([]time.Duration{500 * time.Microsecond, time.Millisecond, 3 * time.Millisecond, 2 * time.Second})
+	require.Equal(t, 1, bins[0].Count)           // < 1ms
+	require.Equal(t, 1, bins[1].Count)           // < 2ms
+	require.Equal(t, 1, bins[2].Count)           // < 5ms
+	require.Equal(t, 1, bins[len(bins)-1].Count) // >= 1s
+	require.Equal(t, time.Duration(0), bins[len(bins)-1].UpperBound)
+}
+
+func TestBench(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	qnames := make(map[string]int)
+	digOne := func(ctx context.Context, p dig.DigOneParams) dig.DigOneResult {
+		mu.Lock()
+		qnames[p.Qname]++
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		if p.Qname == "missing.example.com" {
+			return dig.DigOneResult{Answers: nil, TTLs: nil, RTT: time.Millisecond, Err: dig.RcodeError(dns.RcodeNameError)}
+		}
+		return dig.DigOneResult{Answers: []string{"1.1.1.1"}, TTLs: nil, RTT: time.Millisecond, Err: nil}
+	}
+
+	p := dig.EmptyDigOneparams()
+	p.Rtype = dns.TypeA
+
+	tests := []struct {
+		name string
+		qps  float64
+	}{
+		{name: "closedLoop", qps: 0},
+		{name: "openLoop", qps: 500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Bench(context.Background(), digOne, Params{
+				DigOneParams: p,
+				Nameservers:  []string{"198.51.100.1:53", "198.51.100.2:53"},
+				Qnames:       []WeightedQname{{Qname: "www.example.com", Weight: 3}, {Qname: "missing.example.com", Weight: 1}},
+				QPS:          tt.qps,
+				Workers:      4,
+				Duration:     200 * time.Millisecond,
+				Interval:     50 * time.Millisecond,
+			})
+
+			require.Greater(t, report.Sent, 20)
+			outcomeTotal := 0
+			outcomes := make(map[string]int)
+			for _, o := range report.Outcomes {
+				outcomeTotal += o.Count
+				outcomes[o.String] = o.Count
+			}
+			require.Equal(t, report.Sent, outcomeTotal)
+			require.Greater(t, outcomes["NOERROR"], outcomes["NXDOMAIN"])
+			require.Equal(t, time.Millisecond, report.Latency.P50)
+			require.GreaterOrEqual(t, len(report.Intervals), 3)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package bench
+
+import (
+	"time"
+
+	"go.bbkane.com/shovel/counter"
+	"go.bbkane.com/shovel/dig"
+)
+
+// histogramBounds are the upper bounds of the latency histogram bins. A final bin holds everything slower
+func histogramBounds() []time.Duration {
+	return []time.Duration{
+		1 * time.Millisecond,
+		2 * time.Millisecond,
+		5 * time.Millisecond,
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		50 * time.Millisecond,
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		500 * time.Millisecond,
+		time.Second,
+	}
+}
+
+// HistogramBin counts latencies under UpperBound and at or above the previous bin's bound. The last bin's UpperBound is 0, meaning no bound
+type HistogramBin struct {
+	UpperBound time.Duration
+	Count      int
+}
+
+// Interval summarizes the queries sent during one interval of the run
+type Interval struct {
+	// Start of the interval, relative to the start of the run
+	Start    time.Duration
+	Sent     int
+	QPS      float64
+	Timeouts int
+	Latency  dig.LatencyStats
+}
+
+// Report of a benchmark
+type Report struct {
+	Elapsed time.Duration
+	// Sent counts queries that completed before the run ended
+	Sent int
+	QPS  float64
+	// Dropped counts queries that weren't sent at the target QPS because every worker was busy
+	Dropped int
+	// Outcomes counts rcodes, NODATA, timeouts and other errors
+	Outcomes  []counter.StringCount
+	Latency   dig.LatencyStats
+	Histogram []HistogramBin
+	Intervals []Interval
+}
+
+func newHistogram(rtts []time.Duration) []HistogramBin {
+	bounds := histogramBounds()
+	bins := make([]HistogramBin, len(bounds)+1)
+	for i, b := range bounds {
+		bins[i].UpperBound = b
+	}
+	for _, rtt := range rtts {
+		i := 0
+		for i < len(bounds) && rtt >= bounds[i] {
+			i++
+		}
+		bins[i].Count++
+	}
+	return bins
+}
+
+func newReport(c *collector, elapsed time.Duration) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sent := 0
+	intervals := make([]Interval, 0, len(c.intervals))
+	for i, agg := range c.intervals {
+		sent += agg.sent
+		length := c.interval
+		// the last interval may be cut short
+		if end := time.Duration(i+1) * c.interval; end > elapsed {
+			length = max(elapsed-time.Duration(i)*c.interval, time.Millisecond)
+		}
+		intervals = append(intervals, Interval{
+			Start:    time.Duration(i) * c.interval,
+			Sent:     agg.sent,
+			QPS:      float64(agg.sent) / length.Seconds(),
+			Timeouts: agg.timeouts,
+			Latency:  dig.NewLatencyStats(agg.rtts),
+		})
+	}
+
+	return Report{
+		Elapsed:   elapsed,
+		Sent:      sent,
+		QPS:       float64(sent) / elapsed.Seconds(),
+		Dropped:   c.dropped,
+		Outcomes:  c.outcomes.AsSortedSlice(),
+		Latency:   dig.NewLatencyStats(c.rtts),
+		Histogram: newHistogram(c.rtts),
+		Intervals: intervals,
+	}
+}
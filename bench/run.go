@@ -0,0 +1,142 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"go.bbkane.com/shovel/dig"
+	"go.bbkane.com/shovel/digcombine"
+	"go.bbkane.com/warg/wargcore"
+)
+
+// histogramBar is the widest bar in the latency histogram
+const histogramBar = 40
+
+func printSummary(t table.Writer, r Report) {
+	t.SetTitle("Summary")
+	t.AppendRow(table.Row{"Elapsed", r.Elapsed.Round(time.Millisecond)})
+	t.AppendRow(table.Row{"Sent", r.Sent})
+	t.AppendRow(table.Row{"QPS", strconv.FormatFloat(r.QPS, 'f', 1, 64)})
+	t.AppendRow(table.Row{"Dropped", r.Dropped})
+	t.AppendRow(table.Row{"Latency", r.Latency.String()})
+	t.AppendSeparator()
+	for _, o := range r.Outcomes {
+		t.AppendRow(table.Row{o.String, fmt.Sprintf("%d (%.1f%%)", o.Count, 100*float64(o.Count)/float64(max(r.Sent, 1)))})
+	}
+}
+
+func printHistogram(t table.Writer, bins []HistogramBin) {
+	t.SetTitle("Latency Histogram")
+	t.AppendHeader(table.Row{"Latency", "Count", ""})
+	maxCount := 1
+	for _, b := range bins {
+		maxCount = max(maxCount, b.Count)
+	}
+	for i, b := range bins {
+		label := ""
+		switch {
+		case b.UpperBound == 0:
+			label = ">= " + bins[i-1].UpperBound.String()
+		default:
+			label = "< " + b.UpperBound.String()
+		}
+		t.AppendRow(table.Row{label, b.Count, strings.Repeat("#", b.Count*histogramBar/maxCount)})
+	}
+}
+
+func printIntervals(t table.Writer, intervals []Interval) {
+	t.SetTitle("Over Time")
+	t.AppendHeader(table.Row{"Start", "Sent", "QPS", "Timeouts", "p50", "p90", "p99", "Max"})
+	for _, i := range intervals {
+		t.AppendRow(table.Row{
+			i.Start.String(),
+			i.Sent,
+			strconv.FormatFloat(i.QPS, 'f', 1, 64),
+			i.Timeouts,
+			i.Latency.P50.Round(100 * time.Microsecond),
+			i.Latency.P90.Round(100 * time.Microsecond),
+			i.Latency.P99.Round(100 * time.Microsecond),
+			i.Latency.Max.Round(100 * time.Microsecond),
+		})
+	}
+}
+
+func Run(cmdCtx wargcore.Context) error {
+	qnames, err := ParseWeightedQnames(cmdCtx.Flags["--qname"].([]string))
+	if err != nil {
+		return err
+	}
+	rtypes, err := digcombine.ConvertRTypes([]string{cmdCtx.Flags["--rtype"].(string)})
+	if err != nil {
+		return err
+	}
+	nameserverMap, _ := cmdCtx.Flags["--nameserver-map"].(map[string]string)
+	nameservers, _, err := digcombine.ParseNameservers(cmdCtx.Flags["--nameserver"].([]string), nameserverMap)
+	if err != nil {
+		return err
+	}
+
+	qps := cmdCtx.Flags["--qps"].(int)
+	workers := cmdCtx.Flags["--workers"].(int)
+	duration := cmdCtx.Flags["--duration"].(time.Duration)
+	interval := cmdCtx.Flags["--interval"].(time.Duration)
+	if qps < 0 {
+		return errors.New("--qps can't be negative")
+	}
+	if workers < 1 {
+		return errors.New("--workers must be at least 1")
+	}
+	if duration <= 0 || interval <= 0 {
+		return errors.New("--duration and --interval must be positive")
+	}
+
+	digOneParams := dig.EmptyDigOneparams()
+	digOneParams.Proto = cmdCtx.Flags["--protocol"].(string)
+	digOneParams.Rtype = rtypes[0]
+	digOneParams.Timeout = cmdCtx.Flags["--timeout"].(time.Duration)
+	if subnet, exists := cmdCtx.Flags["--subnet"].(string); exists {
+		subnetIP := net.ParseIP(subnet)
+		if subnetIP == nil {
+			return fmt.Errorf("could not parse subnet IP: %s", subnet)
+		}
+		digOneParams.SubnetIP = subnetIP
+	}
+
+	var digOneFunc dig.DigOneFunc = dig.DigOne
+	if replacementDigOneFunc := cmdCtx.Context.Value(dig.DigOneFuncCtxKey{}); replacementDigOneFunc != nil {
+		digOneFunc = replacementDigOneFunc.(dig.DigOneFunc)
+	}
+
+	report := Bench(
+		context.Background(),
+		digOneFunc,
+		Params{
+			DigOneParams: digOneParams,
+			Nameservers:  nameservers,
+			Qnames:       qnames,
+			QPS:          float64(qps),
+			Workers:      workers,
+			Duration:     duration,
+			Interval:     interval,
+		},
+	)
+
+	for _, printTable := range []func(table.Writer){
+		func(t table.Writer) { printSummary(t, report) },
+		func(t table.Writer) { printHistogram(t, report.Histogram) },
+		func(t table.Writer) { printIntervals(t, report.Intervals) },
+	} {
+		t := table.NewWriter()
+		t.SetStyle(table.StyleRounded)
+		t.SetOutputMirror(cmdCtx.Stdout)
+		printTable(t)
+		t.Render()
+	}
+	return nil
+}
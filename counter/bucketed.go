@@ -0,0 +1,77 @@
+package counter
+
+import (
+	"sync"
+	"time"
+)
+
+// StringSliceBucket counts the string slices observed during one time window
+type StringSliceBucket struct {
+	// Start of the window, relative to the start of the counter
+	Start  time.Duration
+	Counts []StringSliceCount
+}
+
+// Total of every count in the bucket
+func (b StringSliceBucket) Total() int {
+	total := 0
+	for _, c := range b.Counts {
+		total += c.Count
+	}
+	return total
+}
+
+// BucketedStringSliceCounter counts string slices per time window. It's safe to call methods concurrently
+type BucketedStringSliceCounter struct {
+	mu      *sync.Mutex
+	start   time.Time
+	width   time.Duration
+	buckets *[]StringSliceCounter
+}
+
+// NewBucketedStringSliceCounter counts in windows of width from start. width must be positive
+func NewBucketedStringSliceCounter(start time.Time, width time.Duration) BucketedStringSliceCounter {
+	return BucketedStringSliceCounter{
+		mu:      &sync.Mutex{},
+		start:   start,
+		width:   width,
+		buckets: &[]StringSliceCounter{},
+	}
+}
+
+// Add a slice observed at a time. Observations before the start are counted in the first window
+func (c *BucketedStringSliceCounter) Add(at time.Time, slice []string) {
+	c.AddN(at, slice, 1)
+}
+
+// AddN counts a slice observed n times at a time
+func (c *BucketedStringSliceCounter) AddN(at time.Time, slice []string, n int) {
+	idx := max(0, int(at.Sub(c.start)/c.width))
+
+	c.mu.Lock()
+	for len(*c.buckets) <= idx {
+		*c.buckets = append(*c.buckets, NewStringSliceCounter())
+	}
+	bucket := (*c.buckets)[idx]
+	c.mu.Unlock()
+
+	bucket.AddN(slice, n)
+}
+
+// AsSortedBuckets returns every window from the start through the last observation, including empty windows.
+// Counts in each window are sorted by slice asc
+func (c *BucketedStringSliceCounter) AsSortedBuckets() []StringSliceBucket {
+	c.mu.Lock()
+	buckets := make([]StringSliceCounter, len(*c.buckets))
+	copy(buckets, *c.buckets)
+	c.mu.Unlock()
+
+	ret := make([]StringSliceBucket, 0, len(buckets))
+	for i, b := range buckets {
+		ret = append(ret, StringSliceBucket{
+			Start:  time.Duration(i) * c.width,
+			Counts: b.AsSortedSlice(),
+		})
+	}
+	return ret
+}
@@ -0,0 +1,35 @@
+package counter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketedStringSliceCounter(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewBucketedStringSliceCounter(start, 10*time.Second)
+	c.Add(start.Add(-time.Second), []string{"a"})
+	c.Add(start.Add(time.Second), []string{"a"})
+	c.Add(start.Add(25*time.Second), []string{"b"})
+	c.Add(start.Add(29*time.Second), []string{"a"})
+	c.AddN(start.Add(5*time.Second), []string{"b"}, 3)
+
+	expected := []StringSliceBucket{
+		{Start: 0, Counts: []StringSliceCount{
+			{StringSlice: []string{"a"}, Count: 2},
+			{StringSlice: []string{"b"}, Count: 3},
+		}},
+		{Start: 10 * time.Second, Counts: nil},
+		{Start: 20 * time.Second, Counts: []StringSliceCount{
+			{StringSlice: []string{"a"}, Count: 1},
+			{StringSlice: []string{"b"}, Count: 1},
+		}},
+	}
+	actual := c.AsSortedBuckets()
+	require.Equal(t, expected, actual)
+	require.Equal(t, 2, actual[2].Total())
+}
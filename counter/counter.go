@@ -0,0 +1,171 @@
+package counter
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Order to sort counts in
+type Order int
+
+const (
+	// ByKey sorts counts by key ascending
+	ByKey Order = iota
+	// ByCountDesc sorts counts by count descending, breaking ties by key
+	ByCountDesc
+	// ByFirstSeen sorts counts in the order their keys were first added
+	ByFirstSeen
+)
+
+// Count of a key
+type Count[K comparable] struct {
+	Key   K
+	Count int
+}
+
+// Counter counts keys. Use New or NewFunc to create one.
+// It's safe to call methods concurrently. Copies of a Counter share its counts; use Snapshot for an independent copy
+type Counter[K comparable] struct {
+	mu     *sync.RWMutex
+	counts map[K]int
+	// firstSeen maps keys to the order they were first added in
+	firstSeen map[K]int
+	compare   func(a, b K) int
+}
+
+// New Counter for keys with a natural order
+func New[K cmp.Ordered]() Counter[K] {
+	return NewFunc(cmp.Compare[K])
+}
+
+// NewFunc creates a Counter whose keys are ordered by compare
+func NewFunc[K comparable](compare func(a, b K) int) Counter[K] {
+	return Counter[K]{
+		mu:        &sync.RWMutex{},
+		counts:    make(map[K]int),
+		firstSeen: make(map[K]int),
+		compare:   compare,
+	}
+}
+
+// Add one to the count for key
+func (c *Counter[K]) Add(key K) {
+	c.AddN(key, 1)
+}
+
+// AddN adds n to the count for key
+func (c *Counter[K]) AddN(key K, n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.addN(key, n)
+}
+
+func (c *Counter[K]) addN(key K, n int) {
+	if _, exists := c.firstSeen[key]; !exists {
+		c.firstSeen[key] = len(c.firstSeen)
+	}
+	c.counts[key] += n
+}
+
+// Merge adds the counts from other. Keys new to c are first seen after the keys already in c
+func (c *Counter[K]) Merge(other Counter[K]) {
+	// snapshot first so merging a counter into itself doesn't deadlock
+	snapshot := other.Snapshot()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, count := range snapshot.Sorted(ByFirstSeen) {
+		c.addN(count.Key, count.Count)
+	}
+}
+
+// Snapshot returns an independent copy of the counts so far. Counting can continue while it's read
+func (c *Counter[K]) Snapshot() Counter[K] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return Counter[K]{
+		mu:        &sync.RWMutex{},
+		counts:    maps.Clone(c.counts),
+		firstSeen: maps.Clone(c.firstSeen),
+		compare:   c.compare,
+	}
+}
+
+// Get the count for key
+func (c *Counter[K]) Get(key K) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.counts[key]
+}
+
+// Len is the number of distinct keys
+func (c *Counter[K]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.counts)
+}
+
+// Total of every count
+func (c *Counter[K]) Total() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.total()
+}
+
+func (c *Counter[K]) total() int {
+	total := 0
+	for _, n := range c.counts {
+		total += n
+	}
+	return total
+}
+
+// Percent of the total counted for key, from 0 to 100. It's 0 for an empty counter
+func (c *Counter[K]) Percent(key K) float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	total := c.total()
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(c.counts[key]) / float64(total)
+}
+
+// Sorted returns the counts in the given order
+func (c *Counter[K]) Sorted(order Order) []Count[K] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ret := make([]Count[K], 0, len(c.counts))
+	for key, n := range c.counts {
+		ret = append(ret, Count[K]{Key: key, Count: n})
+	}
+	slices.SortFunc(ret, func(a, b Count[K]) int {
+		return cmp.Compare(c.firstSeen[a.Key], c.firstSeen[b.Key])
+	})
+	switch order {
+	case ByKey:
+		slices.SortStableFunc(ret, func(a, b Count[K]) int {
+			return c.compare(a.Key, b.Key)
+		})
+	case ByCountDesc:
+		slices.SortStableFunc(ret, func(a, b Count[K]) int {
+			if a.Count != b.Count {
+				return cmp.Compare(b.Count, a.Count)
+			}
+			return c.compare(a.Key, b.Key)
+		})
+	case ByFirstSeen:
+		// already in the order keys were first seen
+	}
+	return ret
+}
+
+// TopN returns the n keys with the highest counts, breaking ties by key
+func (c *Counter[K]) TopN(n int) []Count[K] {
+	ret := c.Sorted(ByCountDesc)
+	return ret[:max(0, min(n, len(ret)))]
+}
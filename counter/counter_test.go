@@ -0,0 +1,135 @@
+package counter
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounterSorted(t *testing.T) {
+	t.Parallel()
+
+	c := New[string]()
+	c.Add("b")
+	c.AddN("c", 3)
+	c.Add("a")
+	c.Add("b")
+
+	tests := []struct {
+		name     string
+		order    Order
+		expected []Count[string]
+	}{
+		{
+			name:     "byKey",
+			order:    ByKey,
+			expected: []Count[string]{{Key: "a", Count: 1}, {Key: "b", Count: 2}, {Key: "c", Count: 3}},
+		},
+		{
+			name:     "byCountDesc",
+			order:    ByCountDesc,
+			expected: []Count[string]{{Key: "c", Count: 3}, {Key: "b", Count: 2}, {Key: "a", Count: 1}},
+		},
+		{
+			name:     "byFirstSeen",
+			order:    ByFirstSeen,
+			expected: []Count[string]{{Key: "b", Count: 2}, {Key: "c", Count: 3}, {Key: "a", Count: 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.expected, c.Sorted(tt.order))
+		})
+	}
+}
+
+func TestCounterMerge(t *testing.T) {
+	t.Parallel()
+
+	a := New[int]()
+	a.Add(1)
+	a.Add(2)
+
+	b := New[int]()
+	b.AddN(3, 2)
+	b.Add(1)
+
+	a.Merge(b)
+
+	require.Equal(t, 3, a.Len())
+	require.Equal(t, 5, a.Total())
+	require.Equal(t, 2, a.Get(1))
+	require.Equal(t, 0, a.Get(4))
+	require.InDelta(t, 40.0, a.Percent(3), 0.001)
+	require.Equal(t, []Count[int]{{Key: 1, Count: 2}, {Key: 2, Count: 1}, {Key: 3, Count: 2}}, a.Sorted(ByFirstSeen))
+	require.Equal(t, []Count[int]{{Key: 1, Count: 2}, {Key: 3, Count: 2}}, a.TopN(2))
+	require.Empty(t, a.TopN(0))
+	require.Len(t, a.TopN(10), 3)
+
+	// b is unchanged
+	require.Equal(t, 3, b.Total())
+}
+
+func TestCounterEmpty(t *testing.T) {
+	t.Parallel()
+
+	c := New[string]()
+	require.Equal(t, 0, c.Total())
+	require.Equal(t, 0.0, c.Percent("a"))
+	require.Empty(t, c.Sorted(ByKey))
+}
+
+func TestCounterConcurrent(t *testing.T) {
+	t.Parallel()
+
+	const goroutines = 8
+	const adds = 1000
+
+	c := New[int]()
+	wg := sync.WaitGroup{}
+	for range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range adds {
+				c.Add(i % 10)
+				if i%100 == 0 {
+					// snapshots are consistent while counting continues
+					snapshot := c.Snapshot()
+					total := 0
+					for _, count := range snapshot.Sorted(ByKey) {
+						total += count.Count
+					}
+					require.Equal(t, snapshot.Total(), total)
+				}
+			}
+			c.Merge(New[int]())
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, goroutines*adds, c.Total())
+	require.Equal(t, 10, c.Len())
+	require.Equal(t, goroutines*adds/10, c.Get(3))
+}
+
+func TestCounterSnapshotIsIndependent(t *testing.T) {
+	t.Parallel()
+
+	c := New[string]()
+	c.Add("a")
+	snapshot := c.Snapshot()
+	c.Add("a")
+	c.Add("b")
+	snapshot.Add("c")
+
+	require.Equal(t, []Count[string]{{Key: "a", Count: 1}, {Key: "c", Count: 1}}, snapshot.Sorted(ByKey))
+	require.Equal(t, []Count[string]{{Key: "a", Count: 2}, {Key: "b", Count: 1}}, c.Sorted(ByKey))
+
+	// merging a counter into itself doubles it
+	c.Merge(c)
+	require.Equal(t, 6, c.Total())
+}
@@ -1,19 +1,30 @@
 package counter
 
-import "sort"
-
+// StringCounter counts strings. It's safe to call methods concurrently
 type StringCounter struct {
-	counter map[string]int
+	counter Counter[string]
 }
 
 func NewStringCounter() StringCounter {
 	return StringCounter{
-		counter: make(map[string]int),
+		counter: New[string](),
 	}
 }
 
 func (c *StringCounter) Add(str string) {
-	c.counter[str]++
+	c.counter.Add(str)
+}
+
+// Merge adds the counts from other
+func (c *StringCounter) Merge(other StringCounter) {
+	c.counter.Merge(other.counter)
+}
+
+// Snapshot returns an independent copy of the counts so far
+func (c *StringCounter) Snapshot() StringCounter {
+	return StringCounter{
+		counter: c.counter.Snapshot(),
+	}
 }
 
 type StringCount struct {
@@ -21,24 +32,14 @@ type StringCount struct {
 	Count  int
 }
 
-// AsSortedSlice returns the counter as a slice sorted by name asc, count asc
+// AsSortedSlice returns the counter as a slice sorted by name asc
 func (c *StringCounter) AsSortedSlice() []StringCount {
 	var ret []StringCount
-	for key, val := range c.counter {
+	for _, count := range c.counter.Sorted(ByKey) {
 		ret = append(ret, StringCount{
-			String: key,
-			Count:  val,
+			String: count.Key,
+			Count:  count.Count,
 		})
 	}
-	sort.Slice(
-		ret,
-		func(i, j int) bool {
-			// NOTE: strings are guaranteed to be unique so not sure this is needed
-			if ret[i].String == ret[j].String {
-				return ret[i].Count < ret[j].Count
-			}
-			return ret[i].String < ret[j].String
-		},
-	)
 	return ret
 }
@@ -1,36 +1,87 @@
 package counter
 
 import (
-	"hash/fnv"
-	"sort"
-
-	"golang.org/x/exp/slices"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 )
 
+// SliceKey encodes a string slice as a map key. Each string is prefixed with its length,
+// so different slices always get different keys, even if their concatenations are equal
+func SliceKey(slice []string) string {
+	sb := strings.Builder{}
+	for _, s := range slice {
+		sb.WriteString(strconv.Itoa(len(s)))
+		sb.WriteByte(':')
+		sb.WriteString(s)
+	}
+	return sb.String()
+}
+
+// StringSliceCounter counts string slices. Slices are compared element by element, so order matters.
+// It's safe to call methods concurrently
 type StringSliceCounter struct {
-	hashToSlice map[uint64][]string
-	hashToCount map[uint64]int
+	// mu guards keyToSlice and is held around calls to counter so the two stay consistent
+	mu         *sync.RWMutex
+	keyToSlice map[string][]string
+	counter    Counter[string]
 }
 
 func NewStringSliceCounter() StringSliceCounter {
+	return newStringSliceCounter(make(map[string][]string))
+}
+
+func newStringSliceCounter(keyToSlice map[string][]string) StringSliceCounter {
 	return StringSliceCounter{
-		hashToSlice: make(map[uint64][]string),
-		hashToCount: make(map[uint64]int),
+		mu:         &sync.RWMutex{},
+		keyToSlice: keyToSlice,
+		counter: NewFunc(func(a, b string) int {
+			return slices.Compare(keyToSlice[a], keyToSlice[b])
+		}),
 	}
 }
 
 func (c *StringSliceCounter) Add(slice []string) {
-	// https://pkg.go.dev/hash@go1.20.2#example-package-BinaryMarshaler
-	// https://stackoverflow.com/a/72562519/2958070
+	c.AddN(slice, 1)
+}
 
-	hasher := fnv.New64()
-	for _, s := range slice {
-		hasher.Write([]byte(s))
+// AddN counts a slice n times
+func (c *StringSliceCounter) AddN(slice []string, n int) {
+	key := SliceKey(slice)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.keyToSlice[key]; !exists {
+		c.keyToSlice[key] = slices.Clone(slice)
 	}
-	hash := hasher.Sum64()
+	c.counter.AddN(key, n)
+}
+
+// Merge adds the counts from other
+func (c *StringSliceCounter) Merge(other StringSliceCounter) {
+	// snapshot first so merging a counter into itself doesn't deadlock
+	snapshot := other.Snapshot()
 
-	c.hashToCount[hash]++
-	c.hashToSlice[hash] = slice // TODO: how expensive is this?
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, slice := range snapshot.keyToSlice {
+		if _, exists := c.keyToSlice[key]; !exists {
+			c.keyToSlice[key] = slice
+		}
+	}
+	c.counter.Merge(snapshot.counter)
+}
+
+// Snapshot returns an independent copy of the counts so far. Counting can continue while it's read
+func (c *StringSliceCounter) Snapshot() StringSliceCounter {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ret := newStringSliceCounter(maps.Clone(c.keyToSlice))
+	ret.counter.Merge(c.counter)
+	return ret
 }
 
 type StringSliceCount struct {
@@ -38,25 +89,17 @@ type StringSliceCount struct {
 	Count       int
 }
 
+// AsSortedSlice returns the counter as a slice sorted by slice asc
 func (c *StringSliceCounter) AsSortedSlice() []StringSliceCount {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	var ret []StringSliceCount
-	for key := range c.hashToCount {
+	for _, count := range c.counter.Sorted(ByKey) {
 		ret = append(ret, StringSliceCount{
-			StringSlice: c.hashToSlice[key],
-			Count:       c.hashToCount[key],
+			StringSlice: c.keyToSlice[count.Key],
+			Count:       count.Count,
 		})
 	}
-
-	sort.Slice(
-		ret,
-		func(i, j int) bool {
-
-			slicesCompare := slices.Compare(ret[i].StringSlice, ret[j].StringSlice)
-			if slicesCompare == 0 {
-				return ret[i].Count < ret[j].Count
-			}
-			return slicesCompare < 0
-		},
-	)
 	return ret
 }
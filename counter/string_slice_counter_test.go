@@ -1,6 +1,8 @@
 package counter
 
 import (
+	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -35,3 +37,80 @@ func TestStringSliceCounter(t *testing.T) {
 	require.Equal(t, expected, actual)
 
 }
+
+func TestStringSliceCounterMerge(t *testing.T) {
+	t.Parallel()
+
+	a := NewStringSliceCounter()
+	a.Add([]string{"a"})
+	a.Add([]string{"b"})
+
+	b := NewStringSliceCounter()
+	b.Add([]string{"a"})
+	b.Add([]string{"a", "b"})
+
+	a.Merge(b)
+
+	expected := []StringSliceCount{
+		{StringSlice: []string{"a"}, Count: 2},
+		{StringSlice: []string{"a", "b"}, Count: 1},
+		{StringSlice: []string{"b"}, Count: 1},
+	}
+	require.Equal(t, expected, a.AsSortedSlice())
+}
+
+func TestStringSliceCounterNoCollisions(t *testing.T) {
+	t.Parallel()
+
+	c := NewStringSliceCounter()
+	c.Add([]string{"ab", "c"})
+	c.Add([]string{"a", "bc"})
+	c.Add([]string{"abc"})
+	c.Add([]string{"c", "ab"})
+	c.Add([]string{})
+
+	expected := []StringSliceCount{
+		{StringSlice: []string{}, Count: 1},
+		{StringSlice: []string{"a", "bc"}, Count: 1},
+		{StringSlice: []string{"ab", "c"}, Count: 1},
+		{StringSlice: []string{"abc"}, Count: 1},
+		{StringSlice: []string{"c", "ab"}, Count: 1},
+	}
+	require.Equal(t, expected, c.AsSortedSlice())
+}
+
+func TestStringSliceCounterKeepsFirstSlice(t *testing.T) {
+	t.Parallel()
+
+	c := NewStringSliceCounter()
+	first := []string{"a"}
+	c.Add(first)
+	first[0] = "changed"
+	c.Add([]string{"a"})
+
+	require.Equal(t, []StringSliceCount{{StringSlice: []string{"a"}, Count: 2}}, c.AsSortedSlice())
+}
+
+func TestStringSliceCounterConcurrent(t *testing.T) {
+	t.Parallel()
+
+	c := NewStringSliceCounter()
+	wg := sync.WaitGroup{}
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				c.Add([]string{"a"})
+				c.Add([]string{strconv.Itoa(i)})
+				snapshot := c.Snapshot()
+				_ = snapshot.AsSortedSlice()
+			}
+		}()
+	}
+	wg.Wait()
+
+	actual := c.AsSortedSlice()
+	require.Len(t, actual, 9)
+	require.Equal(t, StringSliceCount{StringSlice: []string{"a"}, Count: 800}, actual[8])
+}
@@ -2,8 +2,10 @@ package dig
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"net/netip"
 	"sort"
 	"strings"
 	"time"
@@ -19,7 +21,16 @@ type DigOneParams struct {
 	Qname            string
 	Rtype            uint16
 	SubnetIP         net.IP
-	Timeout          time.Duration
+	// SubnetBits is the EDNS client subnet source prefix length. FullSubnetBits sends the full address
+	SubnetBits int
+	Timeout    time.Duration
+	// Tsig signs the query and verifies the response if non-nil
+	Tsig *TsigKey
+	// NoRecurse clears the RD bit so resolvers only answer from their cache
+	NoRecurse bool
+	// OrderedAnswers keeps answers in the order the nameserver returned them instead of sorting them,
+	// so answer sets that only differ in order are counted separately
+	OrderedAnswers bool
 }
 
 func EmptyDigOneparams() DigOneParams {
@@ -29,37 +40,75 @@ func EmptyDigOneparams() DigOneParams {
 		Qname:            "",
 		Rtype:            0,
 		SubnetIP:         nil,
+		SubnetBits:       FullSubnetBits,
 		Timeout:          0,
+		Tsig:             nil,
+		NoRecurse:        false,
+		OrderedAnswers:   false,
 	}
 }
 
+// ErrNotCached is returned by DigOne when `DigOneParams.NoRecurse` is set and the nameserver has no cached answer
+var ErrNotCached = errors.New("not cached")
+
+// ErrNoAnswers is returned by DigOne for NOERROR responses without answers (NODATA)
+var ErrNoAnswers = errors.New("no answers returned")
+
+// ErrRcode is wrapped by errors from DigOne for responses with a non-success rcode. See RcodeError
+var ErrRcode = errors.New("non-success rcode")
+
+// RcodeError is returned by DigOne for responses with a non-success rcode. It wraps ErrRcode. Example: non-success rcode: NXDOMAIN
+func RcodeError(rcode int) error {
+	return rcodeError{rcode: rcode}
+}
+
+// FullSubnetBits is the DigOneParams.SubnetBits that sends the full subnet address, as opposed to a /0 prefix
+const FullSubnetBits = -1
+
 type DigOneFuncCtxKey struct{}
 
-type DigOneFunc func(ctx context.Context, p DigOneParams) ([]string, error)
+type DigOneFunc func(ctx context.Context, p DigOneParams) DigOneResult
 
 type DigOneResult struct {
 	Answers []string
-	Err     error
+	// TTLs of each answer RR, in the order they were received
+	TTLs []uint32
+	// RTT is the round trip time of the exchange. It's 0 if no response was received
+	RTT time.Duration
+	Err error
 }
 
 func DigOneFuncMock(_ context.Context, rets []DigOneResult) DigOneFunc {
 	var i int
-	return func(_ context.Context, p DigOneParams) ([]string, error) {
+	return func(_ context.Context, p DigOneParams) DigOneResult {
 		if i >= len(rets) {
 			panic("Ran out of returns!")
 		}
 		ret := rets[i]
 		i++
-		return ret.Answers, ret.Err
+		return ret
+	}
+}
+
+// dialAddress drops a trailing '.' from a nameserver hostname. Go's resolver doesn't match "localhost." against /etc/hosts
+func dialAddress(nameserverIPPort string) string {
+	host, port, err := net.SplitHostPort(nameserverIPPort)
+	if err != nil || !strings.HasSuffix(host, ".") {
+		return nameserverIPPort
 	}
+	return net.JoinHostPort(strings.TrimSuffix(host, "."), port)
 }
 
 // DigOne a qname! Returns an error for rcode != NOERROR or an empty list of answers.
-// Returns answers sorted alphabetically.
+// Returns answers sorted alphabetically unless `DigOneParams.OrderedAnswers` is set.
+// Truncated responses without answers return ErrTruncated. Truncated responses with answers return the answers that fit.
+// If `DigOneParams.NoRecurse` is set, an empty answer returns ErrNotCached.
+// If `DigOneParams.Tsig` is set, the query is signed and an error wrapping ErrTsig is returned if the response signature can't be verified.
 // If there is both a context deadline and a configured timeout on `DigOneParams`, the earliest of the two takes effect.
-func DigOne(ctx context.Context, p DigOneParams) ([]string, error) {
+func DigOne(ctx context.Context, p DigOneParams) DigOneResult {
 	m := new(dns.Msg)
 	m.SetQuestion(dns.Fqdn(p.Qname), p.Rtype)
+	m.RecursionDesired = !p.NoRecurse
 
 	// Add subnet!
 	// https://github.com/miekg/exdns/blob/d851fa434ad51cb84500b3e18b8aa7d3bead2c51/q/q.go#L209
@@ -86,10 +135,20 @@ func DigOne(ctx context.Context, p DigOneParams) ([]string, error) {
 			e.Family = 2 // IP6
 			e.SourceNetmask = net.IPv6len * 8
 		}
+		if p.SubnetBits != FullSubnetBits {
+			e.SourceNetmask = uint8(p.SubnetBits)
+		}
 		o.Option = append(o.Option, e)
 		m.Extra = append(m.Extra, o)
 	}
 
+	var tsigSecret map[string]string
+	if p.Tsig != nil {
+		// SetTsig must be called last, as the TSIG RR must be the last RR in the additional section
+		m.SetTsig(p.Tsig.Name, p.Tsig.Algorithm, tsigFudge, time.Now().Unix())
+		tsigSecret = map[string]string{p.Tsig.Name: p.Tsig.Secret}
+	}
+
 	client := dns.Client{
 		Net:            p.Proto,
 		UDPSize:        0,
@@ -99,26 +158,50 @@ func DigOne(ctx context.Context, p DigOneParams) ([]string, error) {
 		DialTimeout:    0,
 		ReadTimeout:    0,
 		WriteTimeout:   0,
-		TsigSecret:     nil,
+		TsigSecret:     tsigSecret,
 		TsigProvider:   nil,
 		SingleInflight: false,
 	}
-	in, _, err := client.ExchangeContext(ctx, m, p.NameserverIPPort)
+	in, rtt, err := client.ExchangeContext(ctx, m, dialAddress(p.NameserverIPPort))
+
+	errResult := func(err error) DigOneResult {
+		return DigOneResult{Answers: nil, TTLs: nil, RTT: rtt, Err: err}
+	}
 
 	if err != nil {
-		return nil, fmt.Errorf("exchange err: %w", err)
+		// rtt is only meaningful if we got a response
+		rtt = 0
+		if p.Tsig != nil && isTsigErr(err) {
+			return errResult(fmt.Errorf("%w: %w", ErrTsig, err))
+		}
+		return errResult(fmt.Errorf("exchange err: %w", err))
+	}
+	if p.Tsig != nil {
+		err := verifyTsigResponse(in)
+		if err != nil {
+			return errResult(err)
+		}
 	}
 	if in.Rcode != dns.RcodeSuccess {
-		return nil, fmt.Errorf("non-success rcode: %s", dns.RcodeToString[in.Rcode])
+		return errResult(RcodeError(in.Rcode))
 	}
 
 	if len(in.Answer) < 1 {
+		if in.Truncated {
+			return errResult(ErrTruncated)
+		}
+		if p.NoRecurse {
+			// a resolver without the name cached returns an empty answer or a referral
+			return errResult(ErrNotCached)
+		}
 		// This can happen if we query for CNAME for example
-		return nil, fmt.Errorf("no answers returned")
+		return errResult(ErrNoAnswers)
 	}
 
 	answers := []string{}
+	ttls := []uint32{}
 	for _, e := range in.Answer {
+		ttls = append(ttls, e.Header().Ttl)
 
 		switch t := e.(type) {
 		case *dns.A:
@@ -131,56 +214,123 @@ func DigOne(ctx context.Context, p DigOneParams) ([]string, error) {
 			answers = append(answers, t.Mx)
 		case *dns.NS:
 			answers = append(answers, t.Ns)
+		case *dns.PTR:
+			answers = append(answers, t.Ptr)
 		case *dns.TXT:
 			// NOTE: the dns lib has a MUCH fancier private way to do this
 			// Maybe I should copy that :)
 			answers = append(answers, strings.Join(t.Txt, " "))
 		default:
-			return nil, fmt.Errorf("unknown record type: %T", e)
+			return errResult(fmt.Errorf("%w: %T", ErrUnknownRecordType, e))
 		}
 
 	}
-	sort.Strings(answers)
-	return answers, nil
+	if !p.OrderedAnswers {
+		sort.Strings(answers)
+	}
+	return DigOneResult{Answers: answers, TTLs: ttls, RTT: rtt, Err: nil}
 }
 
 type DigRepeatParams struct {
 	DigOneParams DigOneParams
 	Count        int
+	// Interval to bucket answers over time into DigRepeatResult.AnswersOverTime. 0 doesn't bucket them
+	Interval time.Duration
 }
 
 type DigRepeatResult struct {
 	Answers []counter.StringSliceCount
-	Errors  []counter.StringCount
+	// AnswerTTLs[i] summarizes the TTLs seen for Answers[i]
+	AnswerTTLs []TTLStats
+	// Errors are counted by category. See ClassifyError
+	Errors []ErrorCount
+	// Latency summarizes the RTTs of every response received, including error responses like NXDOMAIN
+	Latency LatencyStats
+	// AnswersOverTime counts answers per DigRepeatParams.Interval. Errors are counted as their ErrorAnswers. nil if Interval is 0
+	AnswersOverTime []counter.StringSliceBucket
 }
 
-// DigRepeat runs DigOne multiple times and sums the answers and errors
+// DigRepeat runs DigOne multiple times and sums the answers and errors.
+// Placeholders in the qname (see ExpandQnameTemplate) are expanded fresh for each dig.
 func DigRepeat(ctx context.Context, p DigRepeatParams, dig DigOneFunc) DigRepeatResult {
 	answerCounter := counter.NewStringSliceCounter()
-	errorCounter := counter.NewStringCounter()
+	errorCounter := newErrorCounter()
+	rtts := []time.Duration{}
+	ttlTracker := newTTLTracker()
+	var bucketed *counter.BucketedStringSliceCounter
+	if p.Interval > 0 {
+		b := counter.NewBucketedStringSliceCounter(time.Now(), p.Interval)
+		bucketed = &b
+	}
 
 	for i := 0; i < p.Count; i++ {
-		answer, err := dig(ctx, p.DigOneParams)
-		if err != nil {
-			errorCounter.Add(err.Error())
+		digOneParams := p.DigOneParams
+		digOneParams.Qname = ExpandQnameTemplate(p.DigOneParams.Qname)
+		res := dig(ctx, digOneParams)
+		if res.RTT > 0 {
+			rtts = append(rtts, res.RTT)
+		}
+		if res.Err != nil {
+			errorCounter.Add(res.Err)
+			if bucketed != nil {
+				bucketed.Add(time.Now(), ErrorAnswers(ClassifyError(res.Err)))
+			}
 		} else {
-			answerCounter.Add(answer)
+			answerCounter.Add(res.Answers)
+			ttlTracker.Add(res.Answers, res.TTLs)
+			if bucketed != nil {
+				bucketed.Add(time.Now(), res.Answers)
+			}
 		}
 	}
+
+	answers := answerCounter.AsSortedSlice()
+	var answersOverTime []counter.StringSliceBucket
+	if bucketed != nil {
+		answersOverTime = bucketed.AsSortedBuckets()
+	}
+	answerTTLs := make([]TTLStats, len(answers))
+	for i := range answers {
+		answerTTLs[i] = ttlTracker.Get(answers[i].StringSlice)
+	}
+
 	return DigRepeatResult{
-		Answers: answerCounter.AsSortedSlice(),
-		Errors:  errorCounter.AsSortedSlice(),
+		Answers:         answers,
+		AnswerTTLs:      answerTTLs,
+		Errors:          errorCounter.AsSortedSlice(),
+		Latency:         NewLatencyStats(rtts),
+		AnswersOverTime: answersOverTime,
+	}
+}
+
+// SubnetString formats a client subnet as an IP, or as a CIDR if bits is a partial prefix length.
+// A nil ip formats as "<nil>", like net.IP(nil).String()
+func SubnetString(ip net.IP, bits int) string {
+	fullBits := net.IPv6len * 8
+	if ip.To4() != nil {
+		fullBits = net.IPv4len * 8
 	}
+	if ip == nil || bits == FullSubnetBits || bits == fullBits {
+		return ip.String()
+	}
+	return fmt.Sprintf("%s/%d", ip, bits)
 }
 
-// CombineDigRepeatParams combines all the slcies passed. Ensure all of them have a length > 0
-func CombineDigRepeatParams(nameservers []string, proto string, qnames []string, rtypes []uint16, subnets []net.IP, count int) []DigRepeatParams {
+// CombineDigRepeatParams combines all the slcies passed. Ensure all of them have a length > 0.
+// An invalid (zero value) subnet means no client subnet.
+func CombineDigRepeatParams(nameservers []string, proto string, qnames []string, rtypes []uint16, subnets []netip.Prefix, count int) []DigRepeatParams {
 	// TODO: range over protos
 	digRepeatParamsSlice := []DigRepeatParams{}
 
 	for _, qname := range qnames {
 		for _, rtype := range rtypes {
 			for _, subnet := range subnets {
+				var subnetIP net.IP
+				subnetBits := FullSubnetBits
+				if subnet.IsValid() {
+					subnetIP = net.IP(subnet.Masked().Addr().AsSlice())
+					subnetBits = subnet.Bits()
+				}
 				for _, nameserver := range nameservers {
 					digRepeatParamsSlice = append(digRepeatParamsSlice, DigRepeatParams{
 						DigOneParams: DigOneParams{
@@ -188,10 +338,12 @@ func CombineDigRepeatParams(nameservers []string, proto string, qnames []string,
 							Proto:            proto,
 							Qname:            qname,
 							Rtype:            rtype,
-							SubnetIP:         subnet,
+							SubnetIP:         subnetIP,
+							SubnetBits:       subnetBits,
 							Timeout:          0, // TODO: implement per-dig timeouts
 						},
-						Count: count,
+						Count:    count,
+						Interval: 0,
 					})
 				}
 			}
@@ -2,22 +2,47 @@ package dig
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"net"
+	"net/netip"
 	"os"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/miekg/dns"
 	"github.com/stretchr/testify/require"
 	"go.bbkane.com/shovel/counter"
+	"go.bbkane.com/shovel/dnstest"
 )
 
+// linkedinHandler answers like the nameservers shovel is typically pointed at: different answers for China and everywhere else
+func linkedinHandler() dns.HandlerFunc {
+	china := netip.MustParsePrefix("101.251.8.0/24")
+	answer := dnstest.Answer("linkedin.com. 300 IN A 13.107.42.14")
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.Question[0].Qtype != dns.TypeA {
+			dnstest.Rcode(dns.RcodeRefused).ServeDNS(w, r)
+			return
+		}
+		if subnet := dnstest.Subnet(r); subnet != nil {
+			if addr, ok := netip.AddrFromSlice(subnet.Address); ok && china.Contains(addr.Unmap()) {
+				dnstest.Rcode(dns.RcodeServerFailure).ServeDNS(w, r)
+				return
+			}
+		}
+		answer.ServeDNS(w, r)
+	}
+}
+
 func Test_digOne(t *testing.T) {
 	t.Parallel()
 
-	integrationTest := os.Getenv("SHOVEL_INTEGRATION_TEST") != ""
-	if !integrationTest {
-		t.Skipf("To run integration tests, run: SHOVEL_INTEGRATION_TEST=1 go test ./... ")
-	}
+	nameserver := dnstest.Start(t, linkedinHandler())
+	_, port, err := net.SplitHostPort(nameserver)
+	require.Nil(t, err)
 
 	tests := []struct {
 		name        string
@@ -32,50 +57,100 @@ func Test_digOne(t *testing.T) {
 			p: DigOneParams{
 				Qname:            "linkedin.com",
 				Rtype:            dns.TypeA,
-				NameserverIPPort: "8.8.8.8:53",
+				NameserverIPPort: nameserver,
 				SubnetIP:         nil,
+				SubnetBits:       FullSubnetBits,
 				Proto:            "udp",
 				Timeout:          0,
+				Tsig:             nil,
+				NoRecurse:        false,
+				OrderedAnswers:   false,
 			},
 			expected:    []string{"13.107.42.14"},
 			expectedErr: false,
 		},
 		{
-			// Google nameserver doesn't work from China
+			// The test zone fails for China like Google's nameserver does
 			name: "linkedinChinaSubnet",
 			dig:  DigOne,
 			p: DigOneParams{
 				Qname:            "linkedin.com",
 				Rtype:            dns.TypeA,
-				NameserverIPPort: "8.8.8.8:53",
+				NameserverIPPort: nameserver,
 				SubnetIP:         net.ParseIP("101.251.8.0"),
+				SubnetBits:       FullSubnetBits,
 				Proto:            "udp",
 				Timeout:          0,
+				Tsig:             nil,
+				NoRecurse:        false,
+				OrderedAnswers:   false,
 			},
 			expected:    []string{"13.107.42.14"},
 			expectedErr: true,
 		},
 		{
-			// Google nameserver doesn't work from China
+			// This can end in '.' or not, it's fine!
 			name: "nsName",
 			dig:  DigOne,
 			p: DigOneParams{
-				Qname: "linkedin.com",
-				Rtype: dns.TypeA,
-				// This can end in '.' or not, it's fine!
-				NameserverIPPort: "dns1.p09.nsone.net:53",
+				Qname:            "linkedin.com",
+				Rtype:            dns.TypeA,
+				NameserverIPPort: net.JoinHostPort("localhost", port),
+				SubnetIP:         nil,
+				SubnetBits:       FullSubnetBits,
+				Proto:            "udp",
+				Timeout:          0,
+				Tsig:             nil,
+				NoRecurse:        false,
+				OrderedAnswers:   false,
+			},
+			expected:    []string{"13.107.42.14"},
+			expectedErr: false,
+		},
+		{
+			// This can end in '.' or not, it's fine!
+			name: "nsNameFQDN",
+			dig:  DigOne,
+			p: DigOneParams{
+				Qname:            "linkedin.com",
+				Rtype:            dns.TypeA,
+				NameserverIPPort: net.JoinHostPort("localhost.", port),
 				SubnetIP:         nil,
+				SubnetBits:       FullSubnetBits,
 				Proto:            "udp",
 				Timeout:          0,
+				Tsig:             nil,
+				NoRecurse:        false,
+				OrderedAnswers:   false,
 			},
 			expected:    []string{"13.107.42.14"},
 			expectedErr: false,
 		},
+		{
+			name: "rcode",
+			dig:  DigOne,
+			p: DigOneParams{
+				Qname:            "linkedin.com",
+				Rtype:            dns.TypeCNAME,
+				NameserverIPPort: nameserver,
+				SubnetIP:         nil,
+				SubnetBits:       FullSubnetBits,
+				Proto:            "udp",
+				Timeout:          0,
+				Tsig:             nil,
+				NoRecurse:        false,
+				OrderedAnswers:   false,
+			},
+			expected:    nil,
+			expectedErr: true,
+		},
 		{
 			name: "mock",
 			dig: DigOneFuncMock(context.Background(), []DigOneResult{
 				{
 					Answers: []string{"hi"},
+					TTLs:    nil,
+					RTT:     0,
 					Err:     nil,
 				},
 			}),
@@ -86,16 +161,16 @@ func Test_digOne(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actual, actualErr := tt.dig(context.Background(), tt.p)
+			actual := tt.dig(context.Background(), tt.p)
 
 			if tt.expectedErr {
-				require.NotNil(t, actualErr)
+				require.NotNil(t, actual.Err)
 				return
 			} else {
-				require.Nil(t, actualErr)
+				require.Nil(t, actual.Err)
 			}
 
-			require.Equal(t, tt.expected, actual)
+			require.Equal(t, tt.expected, actual.Answers)
 
 		})
 	}
@@ -116,11 +191,14 @@ func Test_digVaried(t *testing.T) {
 				{
 					DigOneParams: EmptyDigOneparams(),
 					Count:        1,
+					Interval:     0,
 				},
 			},
 			dig: DigOneFuncMock(context.Background(), []DigOneResult{
 				{
 					Answers: []string{"www.example.com"},
+					TTLs:    []uint32{300},
+					RTT:     time.Millisecond,
 					Err:     nil,
 				},
 			}),
@@ -132,7 +210,18 @@ func Test_digVaried(t *testing.T) {
 							Count:       1,
 						},
 					},
+					AnswerTTLs: []TTLStats{
+						{Min: 300, Max: 300, Decreased: false},
+					},
 					Errors: nil,
+					Latency: LatencyStats{
+						Min: time.Millisecond,
+						P50: time.Millisecond,
+						P90: time.Millisecond,
+						P99: time.Millisecond,
+						Max: time.Millisecond,
+					},
+					AnswersOverTime: nil,
 				},
 			},
 		},
@@ -142,15 +231,20 @@ func Test_digVaried(t *testing.T) {
 				{
 					DigOneParams: EmptyDigOneparams(),
 					Count:        2,
+					Interval:     0,
 				},
 			},
 			dig: DigOneFuncMock(context.Background(), []DigOneResult{
 				{
 					Answers: []string{"www.example.com"},
+					TTLs:    []uint32{300},
+					RTT:     time.Millisecond,
 					Err:     nil,
 				},
 				{
 					Answers: []string{"www.example.com"},
+					TTLs:    []uint32{300},
+					RTT:     time.Millisecond,
 					Err:     nil,
 				},
 			}),
@@ -162,7 +256,70 @@ func Test_digVaried(t *testing.T) {
 							Count:       2,
 						},
 					},
+					AnswerTTLs: []TTLStats{
+						{Min: 300, Max: 300, Decreased: false},
+					},
 					Errors: nil,
+					Latency: LatencyStats{
+						Min: time.Millisecond,
+						P50: time.Millisecond,
+						P90: time.Millisecond,
+						P99: time.Millisecond,
+						Max: time.Millisecond,
+					},
+					AnswersOverTime: nil,
+				},
+			},
+		},
+		{
+			name: "ttlCountdown",
+			params: []DigRepeatParams{
+				{
+					DigOneParams: EmptyDigOneparams(),
+					Count:        3,
+					Interval:     0,
+				},
+			},
+			dig: DigOneFuncMock(context.Background(), []DigOneResult{
+				{
+					Answers: []string{"1.2.3.4", "5.6.7.8"},
+					TTLs:    []uint32{60, 58},
+					RTT:     0,
+					Err:     nil,
+				},
+				{
+					Answers: []string{"1.2.3.4", "5.6.7.8"},
+					TTLs:    []uint32{57, 57},
+					RTT:     0,
+					Err:     nil,
+				},
+				{
+					Answers: nil,
+					TTLs:    nil,
+					RTT:     0,
+					Err:     ErrNoAnswers,
+				},
+			}),
+			expected: []DigRepeatResult{
+				{
+					Answers: []counter.StringSliceCount{
+						{
+							StringSlice: []string{"1.2.3.4", "5.6.7.8"},
+							Count:       2,
+						},
+					},
+					AnswerTTLs: []TTLStats{
+						{Min: 57, Max: 58, Decreased: true},
+					},
+					Errors: []ErrorCount{
+						{
+							Category: ErrorNoData,
+							Count:    1,
+							Samples:  nil,
+						},
+					},
+					Latency:         LatencyStats{Min: 0, P50: 0, P90: 0, P99: 0, Max: 0},
+					AnswersOverTime: nil,
 				},
 			},
 		},
@@ -175,3 +332,486 @@ func Test_digVaried(t *testing.T) {
 		})
 	}
 }
+
+func TestNewLatencyStats(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		rtts     []time.Duration
+		expected LatencyStats
+	}{
+		{
+			name:     "empty",
+			rtts:     nil,
+			expected: LatencyStats{Min: 0, P50: 0, P90: 0, P99: 0, Max: 0},
+		},
+		{
+			name: "one",
+			rtts: []time.Duration{5},
+			expected: LatencyStats{
+				Min: 5,
+				P50: 5,
+				P90: 5,
+				P99: 5,
+				Max: 5,
+			},
+		},
+		{
+			name: "ten",
+			rtts: []time.Duration{10, 9, 8, 7, 6, 5, 4, 3, 2, 1},
+			expected: LatencyStats{
+				Min: 1,
+				P50: 5,
+				P90: 9,
+				P99: 10,
+				Max: 10,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, NewLatencyStats(tt.rtts))
+		})
+	}
+}
+
+func TestQnameTemplate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		qname       string
+		expectedRe  string
+		expectedErr bool
+	}{
+		{
+			name:        "noPlaceholder",
+			qname:       "www.example.com",
+			expectedRe:  `^www\.example\.com$`,
+			expectedErr: false,
+		},
+		{
+			name:        "rand",
+			qname:       "{{rand}}.wild.example.com",
+			expectedRe:  `^[a-z0-9]{12}\.wild\.example\.com$`,
+			expectedErr: false,
+		},
+		{
+			name:        "uuid",
+			qname:       "{{ uuid }}.wild.example.com",
+			expectedRe:  `^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\.wild\.example\.com$`,
+			expectedErr: false,
+		},
+		{
+			name:        "unknown",
+			qname:       "{{nope}}.example.com",
+			expectedRe:  `^\{\{nope\}\}\.example\.com$`,
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateQnameTemplate(tt.qname)
+			if tt.expectedErr {
+				require.NotNil(t, err)
+			} else {
+				require.Nil(t, err)
+			}
+			require.Regexp(t, tt.expectedRe, ExpandQnameTemplate(tt.qname))
+		})
+	}
+}
+
+func TestDigRepeatExpandsQnameTemplate(t *testing.T) {
+	t.Parallel()
+
+	seen := map[string]bool{}
+	digFunc := func(_ context.Context, p DigOneParams) DigOneResult {
+		seen[p.Qname] = true
+		return DigOneResult{Answers: []string{"1.2.3.4"}, TTLs: nil, RTT: 0, Err: nil}
+	}
+	p := DigRepeatParams{
+		DigOneParams: EmptyDigOneparams(),
+		Count:        5,
+		Interval:     0,
+	}
+	p.DigOneParams.Qname = "{{rand}}.wild.example.com"
+
+	DigRepeat(context.Background(), p, digFunc)
+	require.Len(t, seen, 5)
+}
+
+func TestForwardConfirm(t *testing.T) {
+	t.Parallel()
+
+	mock := DigOneFuncMock(context.Background(), []DigOneResult{
+		{Answers: []string{"192.0.2.1", "192.0.2.2"}, TTLs: nil, RTT: 0, Err: nil},
+		{Answers: []string{"192.0.2.9"}, TTLs: nil, RTT: 0, Err: nil},
+	})
+	digFunc := func(ctx context.Context, p DigOneParams) DigOneResult {
+		// confirming queries recurse even when the PTR lookup was a cache snoop
+		require.False(t, p.NoRecurse)
+		return mock(ctx, p)
+	}
+	p := EmptyDigOneparams()
+	p.NoRecurse = true
+
+	actual := ForwardConfirm(
+		context.Background(),
+		digFunc,
+		p,
+		netip.MustParseAddr("192.0.2.1"),
+		[]string{"good.example.com.", "bad.example.com."},
+	)
+	expected := []ForwardConfirmation{
+		{Target: "good.example.com.", Addrs: []string{"192.0.2.1", "192.0.2.2"}, Confirmed: true, Err: nil},
+		{Target: "bad.example.com.", Addrs: []string{"192.0.2.9"}, Confirmed: false, Err: nil},
+	}
+	require.Equal(t, expected, actual)
+}
+
+func TestDigOneWire(t *testing.T) {
+	t.Parallel()
+
+	answer := dnstest.Answer("www.example.com. 300 IN A 192.0.2.1", "www.example.com. 300 IN A 192.0.2.2")
+
+	tests := []struct {
+		name        string
+		handler     dns.Handler
+		params      func(p DigOneParams) DigOneParams
+		expected    []string
+		expectedErr error
+	}{
+		{
+			name:        "udp",
+			handler:     answer,
+			params:      func(p DigOneParams) DigOneParams { return p },
+			expected:    []string{"192.0.2.1", "192.0.2.2"},
+			expectedErr: nil,
+		},
+		{
+			name:    "tcp",
+			handler: answer,
+			params: func(p DigOneParams) DigOneParams {
+				p.Proto = "tcp"
+				return p
+			},
+			expected:    []string{"192.0.2.1", "192.0.2.2"},
+			expectedErr: nil,
+		},
+		{
+			name:        "sorted",
+			handler:     dnstest.Answer("www.example.com. 300 IN A 192.0.2.2", "www.example.com. 300 IN A 192.0.2.1"),
+			params:      func(p DigOneParams) DigOneParams { return p },
+			expected:    []string{"192.0.2.1", "192.0.2.2"},
+			expectedErr: nil,
+		},
+		{
+			name:    "ordered",
+			handler: dnstest.Answer("www.example.com. 300 IN A 192.0.2.2", "www.example.com. 300 IN A 192.0.2.1"),
+			params: func(p DigOneParams) DigOneParams {
+				p.OrderedAnswers = true
+				return p
+			},
+			expected:    []string{"192.0.2.2", "192.0.2.1"},
+			expectedErr: nil,
+		},
+		{
+			name:        "truncatedUDP",
+			handler:     dnstest.Truncate(answer),
+			params:      func(p DigOneParams) DigOneParams { return p },
+			expected:    nil,
+			expectedErr: ErrTruncated,
+		},
+		{
+			name: "truncatedWithAnswers",
+			handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+				m := new(dns.Msg)
+				m.SetReply(r)
+				m.Truncated = true
+				rr, _ := dns.NewRR("www.example.com. 300 IN A 192.0.2.1")
+				m.Answer = append(m.Answer, rr)
+				_ = w.WriteMsg(m)
+			}),
+			params:      func(p DigOneParams) DigOneParams { return p },
+			expected:    []string{"192.0.2.1"},
+			expectedErr: nil,
+		},
+		{
+			name:    "truncatedTCP",
+			handler: dnstest.Truncate(answer),
+			params: func(p DigOneParams) DigOneParams {
+				p.Proto = "tcp"
+				return p
+			},
+			expected:    []string{"192.0.2.1", "192.0.2.2"},
+			expectedErr: nil,
+		},
+		{
+			name:        "nxdomain",
+			handler:     dnstest.Rcode(dns.RcodeNameError),
+			params:      func(p DigOneParams) DigOneParams { return p },
+			expected:    nil,
+			expectedErr: ErrRcode,
+		},
+		{
+			name:    "notCached",
+			handler: dnstest.Answer(),
+			params: func(p DigOneParams) DigOneParams {
+				p.NoRecurse = true
+				return p
+			},
+			expected:    nil,
+			expectedErr: ErrNotCached,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := EmptyDigOneparams()
+			p.Qname = "www.example.com"
+			p.Rtype = dns.TypeA
+			p.Proto = "udp"
+			p.Timeout = time.Second
+			p.NameserverIPPort = dnstest.Start(t, tt.handler)
+
+			actual := DigOne(context.Background(), tt.params(p))
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, actual.Err, tt.expectedErr)
+				return
+			}
+			require.Nil(t, actual.Err)
+			require.Equal(t, tt.expected, actual.Answers)
+			require.Greater(t, actual.RTT, time.Duration(0))
+		})
+	}
+}
+
+func TestDigOneSendsSubnet(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		subnet          string
+		bits            int
+		expectedNetmask uint8
+		expectedString  string
+	}{
+		{name: "prefix", subnet: "101.251.8.0", bits: 24, expectedNetmask: 24, expectedString: "101.251.8.0/24"},
+		{name: "full", subnet: "101.251.8.1", bits: FullSubnetBits, expectedNetmask: 32, expectedString: "101.251.8.1"},
+		{name: "zeroPrefix", subnet: "0.0.0.0", bits: 0, expectedNetmask: 0, expectedString: "0.0.0.0/0"},
+		{name: "fullIPv6", subnet: "2001:db8::1", bits: FullSubnetBits, expectedNetmask: 128, expectedString: "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := dnstest.Record(dnstest.Answer("www.example.com. 300 IN A 192.0.2.1"))
+			p := EmptyDigOneparams()
+			p.Qname = "www.example.com"
+			p.Rtype = dns.TypeA
+			p.Proto = "udp"
+			p.NameserverIPPort = dnstest.Start(t, rec)
+			p.SubnetIP = net.ParseIP(tt.subnet)
+			p.SubnetBits = tt.bits
+			p.NoRecurse = true
+
+			actual := DigOne(context.Background(), p)
+			require.Nil(t, actual.Err)
+
+			queries := rec.Queries()
+			require.Len(t, queries, 1)
+			require.False(t, queries[0].RecursionDesired)
+			ecs := dnstest.Subnet(queries[0])
+			require.NotNil(t, ecs)
+			require.Equal(t, tt.expectedNetmask, ecs.SourceNetmask)
+			require.Equal(t, tt.subnet, ecs.Address.String())
+			require.Equal(t, tt.expectedString, SubnetString(p.SubnetIP, p.SubnetBits))
+		})
+	}
+}
+
+func TestCombineDigRepeatParamsZeroPrefix(t *testing.T) {
+	t.Parallel()
+
+	params := CombineDigRepeatParams(
+		[]string{"8.8.8.8:53"},
+		"udp",
+		[]string{"www.example.com"},
+		[]uint16{dns.TypeA},
+		[]netip.Prefix{netip.MustParsePrefix("0.0.0.0/0"), {}},
+		1,
+	)
+	require.Len(t, params, 2)
+	require.Equal(t, 0, params[0].DigOneParams.SubnetBits)
+	require.Equal(t, "0.0.0.0", params[0].DigOneParams.SubnetIP.String())
+	require.Nil(t, params[1].DigOneParams.SubnetIP)
+	require.Equal(t, FullSubnetBits, params[1].DigOneParams.SubnetBits)
+}
+
+func TestDigOneTimeout(t *testing.T) {
+	t.Parallel()
+
+	p := EmptyDigOneparams()
+	p.Qname = "www.example.com"
+	p.Rtype = dns.TypeA
+	p.Proto = "udp"
+	p.Timeout = 50 * time.Millisecond
+	p.NameserverIPPort = dnstest.Start(t, dnstest.Drop())
+
+	actual := DigOne(context.Background(), p)
+	var netErr net.Error
+	require.ErrorAs(t, actual.Err, &netErr)
+	require.True(t, netErr.Timeout())
+	require.Equal(t, ErrorTimeout, ClassifyError(actual.Err))
+	require.Equal(t, time.Duration(0), actual.RTT)
+}
+
+func TestDigRepeatWire(t *testing.T) {
+	t.Parallel()
+
+	p := DigRepeatParams{
+		DigOneParams: EmptyDigOneparams(),
+		Count:        3,
+		Interval:     0,
+	}
+	p.DigOneParams.Qname = "www.example.com"
+	p.DigOneParams.Rtype = dns.TypeA
+	p.DigOneParams.Proto = "udp"
+	p.DigOneParams.Timeout = time.Second
+	p.DigOneParams.NameserverIPPort = dnstest.Start(t, dnstest.Sequence(
+		dnstest.Answer("www.example.com. 300 IN A 192.0.2.1"),
+		dnstest.Rcode(dns.RcodeServerFailure),
+		dnstest.Answer("www.example.com. 200 IN A 192.0.2.1"),
+	))
+
+	actual := DigRepeat(context.Background(), p, DigOne)
+	require.Equal(t, []counter.StringSliceCount{{StringSlice: []string{"192.0.2.1"}, Count: 2}}, actual.Answers)
+	require.Equal(t, []TTLStats{{Min: 200, Max: 300, Decreased: true}}, actual.AnswerTTLs)
+	require.Equal(t, []ErrorCount{{Category: ErrorServfail, Count: 1, Samples: nil}}, actual.Errors)
+}
+
+func TestDigRepeatIntervalCountsErrors(t *testing.T) {
+	t.Parallel()
+
+	p := DigRepeatParams{
+		DigOneParams: EmptyDigOneparams(),
+		Count:        3,
+		Interval:     time.Hour,
+	}
+	dig := DigOneFuncMock(context.Background(), []DigOneResult{
+		{Answers: []string{"192.0.2.1"}, TTLs: nil, RTT: 0, Err: nil},
+		{Answers: nil, TTLs: nil, RTT: 0, Err: RcodeError(dns.RcodeServerFailure)},
+		{Answers: []string{"192.0.2.1"}, TTLs: nil, RTT: 0, Err: nil},
+	})
+
+	actual := DigRepeat(context.Background(), p, dig)
+	require.Len(t, actual.AnswersOverTime, 1)
+	require.Equal(t, []counter.StringSliceCount{
+		{StringSlice: []string{"192.0.2.1"}, Count: 2},
+		{StringSlice: []string{"error: SERVFAIL"}, Count: 1},
+	}, actual.AnswersOverTime[0].Counts)
+}
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+
+	refused := fmt.Errorf("exchange err: %w", &net.OpError{
+		Op:     "read",
+		Net:    "udp",
+		Source: nil,
+		Addr:   nil,
+		Err:    os.NewSyscallError("read", syscall.ECONNREFUSED),
+	})
+
+	tests := []struct {
+		name     string
+		err      error
+		expected ErrorCategory
+	}{
+		{name: "nxdomain", err: RcodeError(dns.RcodeNameError), expected: ErrorNXDomain},
+		{name: "servfail", err: RcodeError(dns.RcodeServerFailure), expected: ErrorServfail},
+		{name: "otherRcode", err: RcodeError(dns.RcodeNotImplemented), expected: ErrorCategory("NOTIMP")},
+		{name: "nodata", err: ErrNoAnswers, expected: ErrorNoData},
+		{name: "notCached", err: ErrNotCached, expected: ErrorNotCached},
+		{name: "truncated", err: ErrTruncated, expected: ErrorTruncated},
+		{name: "tsig", err: fmt.Errorf("%w: %w", ErrTsig, dns.ErrSig), expected: ErrorTsig},
+		{name: "deadline", err: fmt.Errorf("exchange err: %w", context.DeadlineExceeded), expected: ErrorTimeout},
+		{name: "refused", err: refused, expected: ErrorConnRefused},
+		{name: "tls", err: fmt.Errorf("exchange err: %w", tls.RecordHeaderError{Msg: "not TLS", RecordHeader: [5]byte{}, Conn: nil}), expected: ErrorTLS},
+		{name: "other", err: errors.New("something else"), expected: ErrorOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.expected, ClassifyError(tt.err))
+		})
+	}
+}
+
+func TestDigRepeatErrorSamples(t *testing.T) {
+	t.Parallel()
+
+	// timeouts that only differ by the ephemeral source port, like a real read timeout
+	timeout := func(source, nameserver string) error {
+		return &net.OpError{
+			Op:     "read",
+			Net:    "udp",
+			Source: net.UDPAddrFromAddrPort(netip.MustParseAddrPort(source)),
+			Addr:   net.UDPAddrFromAddrPort(netip.MustParseAddrPort(nameserver)),
+			Err:    os.ErrDeadlineExceeded,
+		}
+	}
+
+	errs := []error{
+		errors.New("sample 1"),
+		RcodeError(dns.RcodeNameError),
+		errors.New("sample 2"),
+		errors.New("sample 1"),
+		errors.New("sample 3"),
+		errors.New("sample 4"),
+		RcodeError(dns.RcodeNameError),
+		timeout("127.0.0.1:54321", "8.8.8.8:53"),
+		timeout("127.0.0.1:54322", "8.8.8.8:53"),
+		timeout("[::1]:54323", "[2001:4860:4860::8888]:53"),
+	}
+	rets := make([]DigOneResult, 0, len(errs))
+	for _, err := range errs {
+		rets = append(rets, DigOneResult{Answers: nil, TTLs: nil, RTT: 0, Err: err})
+	}
+
+	actual := DigRepeat(
+		context.Background(),
+		DigRepeatParams{DigOneParams: EmptyDigOneparams(), Count: len(errs), Interval: 0},
+		DigOneFuncMock(context.Background(), rets),
+	)
+	require.Equal(t, []ErrorCount{
+		{Category: ErrorNXDomain, Count: 2, Samples: nil},
+		{Category: ErrorOther, Count: 5, Samples: []string{"sample 1", "sample 2", "sample 3"}},
+		{Category: ErrorTimeout, Count: 3, Samples: []string{"read udp <addr>-><addr>: i/o timeout"}},
+	}, actual.Errors)
+	require.Equal(t, "other (sample 1)", actual.Errors[1].String())
+	require.Equal(t, "NXDOMAIN", actual.Errors[0].String())
+}
+
+func TestNewElementStats(t *testing.T) {
+	t.Parallel()
+
+	answerSets := []counter.StringSliceCount{
+		{StringSlice: []string{"192.0.2.1", "192.0.2.2"}, Count: 3},
+		{StringSlice: []string{"192.0.2.2", "192.0.2.3"}, Count: 1},
+	}
+
+	expected := []ElementStats{
+		{Answer: "192.0.2.2", Count: 4, Share: 1, AvgPosition: 1.75},
+		{Answer: "192.0.2.1", Count: 3, Share: 0.75, AvgPosition: 1},
+		{Answer: "192.0.2.3", Count: 1, Share: 0.25, AvgPosition: 2},
+	}
+	require.Equal(t, expected, NewElementStats(answerSets))
+	require.Empty(t, NewElementStats(nil))
+}
@@ -0,0 +1,48 @@
+package dig
+
+import (
+	"go.bbkane.com/shovel/counter"
+)
+
+// ElementStats summarizes how often one answer appeared across repeated digs, whatever answers came with it
+type ElementStats struct {
+	Answer string
+	// Count of responses containing the answer
+	Count int
+	// Share of responses with answers that contained it, from 0 to 1
+	Share float64
+	// AvgPosition is the average 1-based position of the answer in the responses containing it.
+	// It's only meaningful with DigOneParams.OrderedAnswers, as answers are sorted otherwise
+	AvgPosition float64
+}
+
+// NewElementStats counts each answer in the answer sets. It's sorted by count desc, then answer asc
+func NewElementStats(answerSets []counter.StringSliceCount) []ElementStats {
+	counts := counter.New[string]()
+	positionSums := make(map[string]int)
+	responses := 0
+	for _, set := range answerSets {
+		responses += set.Count
+		seen := make(map[string]bool)
+		for i, ans := range set.StringSlice {
+			// count repeated answers once per response
+			if seen[ans] {
+				continue
+			}
+			seen[ans] = true
+			counts.AddN(ans, set.Count)
+			positionSums[ans] += (i + 1) * set.Count
+		}
+	}
+
+	ret := []ElementStats{}
+	for _, c := range counts.Sorted(counter.ByCountDesc) {
+		ret = append(ret, ElementStats{
+			Answer:      c.Key,
+			Count:       c.Count,
+			Share:       float64(c.Count) / float64(responses),
+			AvgPosition: float64(positionSums[c.Key]) / float64(c.Count),
+		})
+	}
+	return ret
+}
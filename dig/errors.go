@@ -0,0 +1,191 @@
+package dig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+	"regexp"
+	"slices"
+	"syscall"
+
+	"github.com/miekg/dns"
+	"go.bbkane.com/shovel/counter"
+)
+
+// ErrTruncated is returned by DigOne for responses with the TC bit set and no answers. Retrying over TCP usually gets the full response
+var ErrTruncated = errors.New("truncated response")
+
+// ErrUnknownRecordType is wrapped by errors from DigOne for answers of a type it can't format
+var ErrUnknownRecordType = errors.New("unknown record type")
+
+// ErrorCategory groups errors that mean the same thing, even if their messages differ
+type ErrorCategory string
+
+const (
+	ErrorTimeout           ErrorCategory = "timeout"
+	ErrorConnRefused       ErrorCategory = "connection refused"
+	ErrorUnreachable       ErrorCategory = "unreachable"
+	ErrorNameserverLookup  ErrorCategory = "nameserver lookup"
+	ErrorTLS               ErrorCategory = "TLS failure"
+	ErrorTsig              ErrorCategory = "TSIG failure"
+	ErrorTruncated         ErrorCategory = "truncated"
+	ErrorNoData            ErrorCategory = "NODATA"
+	ErrorNotCached         ErrorCategory = "not cached"
+	ErrorUnknownRecordType ErrorCategory = "unknown record type"
+	ErrorOther             ErrorCategory = "other"
+	// Responses with a non-success rcode are categorized by rcode name. These are the common ones
+	ErrorServfail ErrorCategory = "SERVFAIL"
+	ErrorNXDomain ErrorCategory = "NXDOMAIN"
+	ErrorRefused  ErrorCategory = "REFUSED"
+)
+
+// rcodeError is returned by RcodeError so the rcode can be recovered from wrapped errors
+type rcodeError struct {
+	rcode int
+}
+
+func (e rcodeError) Error() string {
+	return ErrRcode.Error() + ": " + dns.RcodeToString[e.rcode]
+}
+
+func (e rcodeError) Unwrap() error {
+	return ErrRcode
+}
+
+// ClassifyError returns the category of an error from DigOne. It returns ErrorOther for errors it doesn't recognize
+func ClassifyError(err error) ErrorCategory {
+	var rErr rcodeError
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var recordHeaderErr tls.RecordHeaderError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var netErr net.Error
+
+	switch {
+	case errors.As(err, &rErr):
+		return ErrorCategory(dns.RcodeToString[rErr.rcode])
+	case errors.Is(err, ErrNoAnswers):
+		return ErrorNoData
+	case errors.Is(err, ErrNotCached):
+		return ErrorNotCached
+	case errors.Is(err, ErrTruncated):
+		return ErrorTruncated
+	case errors.Is(err, ErrTsig):
+		return ErrorTsig
+	case errors.Is(err, ErrUnknownRecordType):
+		return ErrorUnknownRecordType
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return ErrorTimeout
+	case errors.As(err, &dnsErr):
+		// the nameserver was given as a hostname that couldn't be resolved
+		if dnsErr.IsTimeout {
+			return ErrorTimeout
+		}
+		return ErrorNameserverLookup
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorConnRefused
+	case errors.Is(err, syscall.ENETUNREACH), errors.Is(err, syscall.EHOSTUNREACH):
+		return ErrorUnreachable
+	case errors.As(err, &certErr),
+		errors.As(err, &recordHeaderErr),
+		errors.As(err, &unknownAuthorityErr),
+		errors.As(err, &hostnameErr):
+		return ErrorTLS
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	default:
+		return ErrorOther
+	}
+}
+
+// ErrorAnswers stands in for the answers of a dig that failed with an error in category, so errors can be counted alongside answers.
+// Example: [error: timeout]
+func ErrorAnswers(category ErrorCategory) []string {
+	return []string{"error: " + string(category)}
+}
+
+// hasFixedMessage reports whether every error in err's category has the same message, so samples add nothing
+func hasFixedMessage(err error) bool {
+	for _, sentinel := range []error{ErrRcode, ErrNoAnswers, ErrNotCached, ErrTruncated} {
+		if errors.Is(err, sentinel) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxErrorSamples is the most distinct messages kept per error category
+const maxErrorSamples = 3
+
+// addrPortRe matches an IP:port or [IPv6]:port, like the ephemeral source address in "read udp 127.0.0.1:54321->8.8.8.8:53"
+var addrPortRe = regexp.MustCompile(`(?:\d{1,3}(?:\.\d{1,3}){3}|\[[0-9a-fA-F:.%]+\]):\d+`)
+
+// normalizeSample replaces addresses in an error message so failures that only differ by source port share a sample
+func normalizeSample(msg string) string {
+	return addrPortRe.ReplaceAllString(msg, "<addr>")
+}
+
+// ErrorCount counts the errors in a category
+type ErrorCount struct {
+	Category ErrorCategory
+	Count    int
+	// Samples of distinct messages in the category, in the order they were first seen.
+	// Empty for categories where every error has the same message, like NXDOMAIN
+	Samples []string
+}
+
+// String is the category, followed by its first sample if there is one. Example: timeout (exchange err: read udp 127.0.0.1:53: i/o timeout)
+func (e ErrorCount) String() string {
+	if len(e.Samples) == 0 {
+		return string(e.Category)
+	}
+	return string(e.Category) + " (" + e.Samples[0] + ")"
+}
+
+// Lines are the category followed by each sample, for multi-line displays
+func (e ErrorCount) Lines() []string {
+	return append([]string{string(e.Category)}, e.Samples...)
+}
+
+// errorCounter counts errors by category and keeps a few sample messages for each
+type errorCounter struct {
+	counts  counter.Counter[ErrorCategory]
+	samples map[ErrorCategory][]string
+}
+
+func newErrorCounter() errorCounter {
+	return errorCounter{
+		counts:  counter.New[ErrorCategory](),
+		samples: make(map[ErrorCategory][]string),
+	}
+}
+
+func (c *errorCounter) Add(err error) {
+	category := ClassifyError(err)
+	c.counts.Add(category)
+	if hasFixedMessage(err) {
+		return
+	}
+	msg := normalizeSample(err.Error())
+	samples := c.samples[category]
+	if len(samples) < maxErrorSamples && !slices.Contains(samples, msg) {
+		c.samples[category] = append(samples, msg)
+	}
+}
+
+// AsSortedSlice returns the counts sorted by category asc. Returns nil if nothing was counted
+func (c *errorCounter) AsSortedSlice() []ErrorCount {
+	var ret []ErrorCount
+	for _, count := range c.counts.Sorted(counter.ByKey) {
+		ret = append(ret, ErrorCount{
+			Category: count.Key,
+			Count:    count.Count,
+			Samples:  c.samples[count.Key],
+		})
+	}
+	return ret
+}
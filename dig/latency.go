@@ -0,0 +1,58 @@
+package dig
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// LatencyStats summarizes a set of round trip times. All fields are 0 if there were no RTTs
+type LatencyStats struct {
+	Min time.Duration
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// NewLatencyStats computes LatencyStats from unsorted RTTs using the nearest-rank method
+func NewLatencyStats(rtts []time.Duration) LatencyStats {
+	if len(rtts) == 0 {
+		return LatencyStats{Min: 0, P50: 0, P90: 0, P99: 0, Max: 0}
+	}
+	sorted := slices.Clone(rtts)
+	slices.Sort(sorted)
+
+	// https://en.wikipedia.org/wiki/Percentile#The_nearest-rank_method
+	percentile := func(p int) time.Duration {
+		rank := (p*len(sorted) + 99) / 100
+		return sorted[max(rank, 1)-1]
+	}
+
+	return LatencyStats{
+		Min: sorted[0],
+		P50: percentile(50),
+		P90: percentile(90),
+		P99: percentile(99),
+		Max: sorted[len(sorted)-1],
+	}
+}
+
+// IsZero reports whether no RTTs were recorded
+func (l LatencyStats) IsZero() bool {
+	return l.Max == 0
+}
+
+// String formats the stats on multiple lines, rounded to 0.1ms
+func (l LatencyStats) String() string {
+	if l.IsZero() {
+		return ""
+	}
+	r := func(d time.Duration) time.Duration {
+		return d.Round(100 * time.Microsecond)
+	}
+	return fmt.Sprintf(
+		"min: %s\np50: %s\np90: %s\np99: %s\nmax: %s",
+		r(l.Min), r(l.P50), r(l.P90), r(l.P99), r(l.Max),
+	)
+}
@@ -0,0 +1,50 @@
+package dig
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+
+	"github.com/miekg/dns"
+)
+
+// ForwardConfirmation is the result of resolving a PTR target back to addresses
+type ForwardConfirmation struct {
+	Target string
+	// Addrs the target resolved to
+	Addrs []string
+	// Confirmed is true if the original IP is among Addrs
+	Confirmed bool
+	Err       error
+}
+
+// ForwardConfirm resolves each PTR target to A or AAAA records (matching the family of ip)
+// using the nameserver and other settings from p, and checks that ip is among the results.
+// The confirming queries always recurse, even if p.NoRecurse is set, since the targets are unlikely to be cached.
+func ForwardConfirm(ctx context.Context, digOne DigOneFunc, p DigOneParams, ip netip.Addr, targets []string) []ForwardConfirmation {
+	rtype := dns.TypeA
+	if !ip.Unmap().Is4() {
+		rtype = dns.TypeAAAA
+	}
+
+	ret := []ForwardConfirmation{}
+	for _, target := range targets {
+		forwardParams := p
+		forwardParams.Qname = target
+		forwardParams.Rtype = rtype
+		forwardParams.NoRecurse = false
+
+		res := digOne(ctx, forwardParams)
+		confirmed := slices.ContainsFunc(res.Answers, func(answer string) bool {
+			addr, err := netip.ParseAddr(answer)
+			return err == nil && addr.Unmap() == ip.Unmap()
+		})
+		ret = append(ret, ForwardConfirmation{
+			Target:    target,
+			Addrs:     res.Answers,
+			Confirmed: confirmed,
+			Err:       res.Err,
+		})
+	}
+	return ret
+}
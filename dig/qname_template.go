@@ -0,0 +1,66 @@
+package dig
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// qnameTemplateRe matches placeholders like {{rand}} in qnames
+var qnameTemplateRe = regexp.MustCompile(`\{\{\s*(\w*)\s*\}\}`)
+
+// randLabel returns a random DNS label of lowercase letters and digits
+func randLabel() string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	const length = 12
+	label := make([]byte, length)
+	for i := range label {
+		label[i] = alphabet[rand.N(len(alphabet))]
+	}
+	return string(label)
+}
+
+func qnameTemplateFuncs() map[string]func() string {
+	return map[string]func() string{
+		"rand": randLabel,
+		"uuid": uuid.NewString,
+	}
+}
+
+// ValidateQnameTemplate returns an error if qname contains an unknown placeholder.
+// Known placeholders are {{rand}} (a random 12 character label) and {{uuid}} (a random UUID).
+func ValidateQnameTemplate(qname string) error {
+	funcs := qnameTemplateFuncs()
+	for _, match := range qnameTemplateRe.FindAllStringSubmatch(qname, -1) {
+		if _, exists := funcs[match[1]]; !exists {
+			return fmt.Errorf("unknown placeholder %s in qname: %s", match[0], qname)
+		}
+	}
+	return nil
+}
+
+// ExpandQnameTemplate replaces each placeholder in qname with a fresh random value,
+// so every query misses resolver caches. Unknown placeholders are left as-is; see ValidateQnameTemplate.
+func ExpandQnameTemplate(qname string) string {
+	funcs := qnameTemplateFuncs()
+	matches := qnameTemplateRe.FindAllStringSubmatchIndex(qname, -1)
+	if len(matches) == 0 {
+		return qname
+	}
+	sb := strings.Builder{}
+	last := 0
+	for _, m := range matches {
+		sb.WriteString(qname[last:m[0]])
+		if f, exists := funcs[qname[m[2]:m[3]]]; exists {
+			sb.WriteString(f())
+		} else {
+			sb.WriteString(qname[m[0]:m[1]])
+		}
+		last = m[1]
+	}
+	sb.WriteString(qname[last:])
+	return sb.String()
+}
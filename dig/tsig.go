@@ -0,0 +1,81 @@
+package dig
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ErrTsig is wrapped by errors from DigOne when a TSIG signed response can't be verified
+var ErrTsig = errors.New("tsig verification failed")
+
+// tsigFudge is the allowed clock skew in seconds between us and the nameserver
+const tsigFudge = 300
+
+// TsigKey is a named TSIG key used to sign queries and verify responses
+type TsigKey struct {
+	// Name of the key in canonical form (lowercase fqdn)
+	Name string
+	// Algorithm is one of the dns.Hmac* constants
+	Algorithm string
+	// Secret is the base64 encoded shared secret
+	Secret string
+}
+
+func tsigAlgorithms() map[string]string {
+	return map[string]string{
+		"hmac-md5":    dns.HmacMD5,
+		"hmac-sha1":   dns.HmacSHA1,
+		"hmac-sha224": dns.HmacSHA224,
+		"hmac-sha256": dns.HmacSHA256,
+		"hmac-sha384": dns.HmacSHA384,
+		"hmac-sha512": dns.HmacSHA512,
+	}
+}
+
+// ParseTsigKey parses a key name and a value of the form <algorithm>:<base64 secret>.
+// Example: ParseTsigKey("mykey", "hmac-sha256:c2VjcmV0")
+func ParseTsigKey(name string, value string) (TsigKey, error) {
+	algorithmStr, secret, found := strings.Cut(value, ":")
+	if !found {
+		return TsigKey{}, fmt.Errorf("tsig key %s must be of the form <algorithm>:<base64 secret>", name)
+	}
+	algorithm, exists := tsigAlgorithms()[strings.ToLower(algorithmStr)]
+	if !exists {
+		return TsigKey{}, fmt.Errorf("unknown tsig algorithm for key %s: %s", name, algorithmStr)
+	}
+	if _, err := base64.StdEncoding.DecodeString(secret); err != nil {
+		return TsigKey{}, fmt.Errorf("tsig secret for key %s is not valid base64: %w", name, err)
+	}
+	return TsigKey{
+		Name:      dns.CanonicalName(name),
+		Algorithm: algorithm,
+		Secret:    secret,
+	}, nil
+}
+
+// isTsigErr reports whether err came from verifying a TSIG signature
+func isTsigErr(err error) bool {
+	for _, tsigErr := range []error{dns.ErrSig, dns.ErrTime, dns.ErrAuth, dns.ErrSecret, dns.ErrKeyAlg} {
+		if errors.Is(err, tsigErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyTsigResponse checks that a response to a signed query is itself signed without error.
+// The signature itself is verified by the dns lib when reading the response.
+func verifyTsigResponse(in *dns.Msg) error {
+	t := in.IsTsig()
+	if t == nil {
+		return fmt.Errorf("%w: response is not signed", ErrTsig)
+	}
+	if t.Error != dns.RcodeSuccess {
+		return fmt.Errorf("%w: %s", ErrTsig, dns.RcodeToString[int(t.Error)])
+	}
+	return nil
+}
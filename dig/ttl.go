@@ -0,0 +1,80 @@
+package dig
+
+import (
+	"fmt"
+	"slices"
+
+	"go.bbkane.com/shovel/counter"
+)
+
+// TTLStats summarizes the TTLs observed for an answer set over repeated digs.
+// Each response contributes the lowest TTL of its answer RRs.
+type TTLStats struct {
+	Min uint32
+	Max uint32
+	// Decreased is true if the TTL went down between repeats.
+	// A TTL counting down means the answer came from a resolver cache,
+	// while a steady full TTL means it came from the authoritative server.
+	Decreased bool
+}
+
+// String formats the TTL range, noting if it counted down
+func (t TTLStats) String() string {
+	ret := fmt.Sprint(t.Min)
+	if t.Min != t.Max {
+		ret = fmt.Sprintf("%d-%d", t.Min, t.Max)
+	}
+	if t.Decreased {
+		ret += " (cached)"
+	}
+	return ret
+}
+
+type ttlObservation struct {
+	stats TTLStats
+	last  uint32
+}
+
+// ttlTracker accumulates TTLStats per answer set
+type ttlTracker struct {
+	observations map[string]*ttlObservation
+}
+
+func newTTLTracker() ttlTracker {
+	return ttlTracker{
+		observations: make(map[string]*ttlObservation),
+	}
+}
+
+// Add records the TTLs of one response. Responses without TTLs (from mocks, for example) are ignored
+func (t *ttlTracker) Add(answers []string, ttls []uint32) {
+	if len(ttls) == 0 {
+		return
+	}
+	ttl := slices.Min(ttls)
+	key := counter.SliceKey(answers)
+
+	o, exists := t.observations[key]
+	if !exists {
+		t.observations[key] = &ttlObservation{
+			stats: TTLStats{Min: ttl, Max: ttl, Decreased: false},
+			last:  ttl,
+		}
+		return
+	}
+	o.stats.Min = min(o.stats.Min, ttl)
+	o.stats.Max = max(o.stats.Max, ttl)
+	if ttl < o.last {
+		o.stats.Decreased = true
+	}
+	o.last = ttl
+}
+
+// Get returns the TTLStats for an answer set, or the zero value if none were recorded
+func (t *ttlTracker) Get(answers []string) TTLStats {
+	o, exists := t.observations[counter.SliceKey(answers)]
+	if !exists {
+		return TTLStats{Min: 0, Max: 0, Decreased: false}
+	}
+	return o.stats
+}
@@ -0,0 +1,109 @@
+package digcombine
+
+import (
+	"fmt"
+	"net/netip"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+type answerMapEntry struct {
+	name string
+	// exactly one of addr, prefix, and pattern is set
+	addr    netip.Addr
+	prefix  netip.Prefix
+	pattern string
+}
+
+// AnswerMap gives friendly names to answers. Entries are IPs, CIDRs, or hostname patterns using path.Match syntax (*.lb.example.com).
+// A nil *AnswerMap is valid and labels nothing.
+type AnswerMap struct {
+	// entries ordered by priority: IPs, then CIDRs from longest to shortest prefix, then patterns
+	entries []answerMapEntry
+}
+
+// ParseAnswerMap parses a map of name to IP, CIDR or hostname pattern
+func ParseAnswerMap(nameToAnswer map[string]string) (*AnswerMap, error) {
+	entries := []answerMapEntry{}
+	for name, answer := range nameToAnswer {
+		entry := answerMapEntry{name: name, addr: netip.Addr{}, prefix: netip.Prefix{}, pattern: ""}
+		if addr, err := netip.ParseAddr(answer); err == nil {
+			entry.addr = addr
+		} else if prefix, err := netip.ParsePrefix(answer); err == nil {
+			entry.prefix = prefix.Masked()
+		} else {
+			pattern := strings.ToLower(dns.Fqdn(answer))
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid answer map entry: %s: %s: %w", name, answer, err)
+			}
+			entry.pattern = pattern
+		}
+		entries = append(entries, entry)
+	}
+
+	priority := func(e answerMapEntry) int {
+		switch {
+		case e.addr.IsValid():
+			return 0
+		case e.prefix.IsValid():
+			return 1
+		default:
+			return 2
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		pi, pj := priority(entries[i]), priority(entries[j])
+		if pi != pj {
+			return pi < pj
+		}
+		if entries[i].prefix.Bits() != entries[j].prefix.Bits() {
+			return entries[i].prefix.Bits() > entries[j].prefix.Bits()
+		}
+		return entries[i].name < entries[j].name
+	})
+
+	return &AnswerMap{entries: entries}, nil
+}
+
+func (e answerMapEntry) matches(answer string) bool {
+	if e.pattern != "" {
+		matched, _ := path.Match(e.pattern, strings.ToLower(dns.Fqdn(answer)))
+		return matched
+	}
+	addr, err := netip.ParseAddr(answer)
+	if err != nil {
+		return false
+	}
+	if e.addr.IsValid() {
+		return e.addr == addr
+	}
+	return e.prefix.Contains(addr)
+}
+
+// Name returns the name of the first entry matching the answer, or false if none match
+func (m *AnswerMap) Name(answer string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	for _, e := range m.entries {
+		if e.matches(answer) {
+			return e.name, true
+		}
+	}
+	return "", false
+}
+
+// Label returns "name (answer)" for the first matching entry. If no entry matches, it returns the answer and false.
+// A nil *AnswerMap returns the answer and true so callers don't flag every answer when no map is passed.
+func (m *AnswerMap) Label(answer string) (string, bool) {
+	if m == nil {
+		return answer, true
+	}
+	if name, matched := m.Name(answer); matched {
+		return name + " (" + answer + ")", true
+	}
+	return answer, false
+}
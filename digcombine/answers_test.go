@@ -0,0 +1,92 @@
+package digcombine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnswerMapLabel(t *testing.T) {
+	t.Parallel()
+
+	answerMap, err := ParseAnswerMap(map[string]string{
+		"lb-us-east":  "10.1.2.3",
+		"us-east":     "10.1.0.0/16",
+		"datacenters": "10.0.0.0/8",
+		"v6":          "2001:db8::/32",
+		"edge":        "*.edge.example.com",
+	})
+	require.Nil(t, err)
+
+	tests := []struct {
+		name            string
+		answer          string
+		expectedLabel   string
+		expectedMatched bool
+	}{
+		{
+			name:            "exactIPBeatsCIDR",
+			answer:          "10.1.2.3",
+			expectedLabel:   "lb-us-east (10.1.2.3)",
+			expectedMatched: true,
+		},
+		{
+			name:            "longestPrefix",
+			answer:          "10.1.9.9",
+			expectedLabel:   "us-east (10.1.9.9)",
+			expectedMatched: true,
+		},
+		{
+			name:            "shortPrefix",
+			answer:          "10.200.0.1",
+			expectedLabel:   "datacenters (10.200.0.1)",
+			expectedMatched: true,
+		},
+		{
+			name:            "ipv6",
+			answer:          "2001:db8::1",
+			expectedLabel:   "v6 (2001:db8::1)",
+			expectedMatched: true,
+		},
+		{
+			name:            "hostnamePattern",
+			answer:          "NODE1.edge.example.com.",
+			expectedLabel:   "edge (NODE1.edge.example.com.)",
+			expectedMatched: true,
+		},
+		{
+			name:            "patternDoesNotMatchBareDomain",
+			answer:          "edge.example.com.",
+			expectedLabel:   "edge.example.com.",
+			expectedMatched: false,
+		},
+		{
+			name:            "unmatchedIP",
+			answer:          "192.0.2.1",
+			expectedLabel:   "192.0.2.1",
+			expectedMatched: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			label, matched := answerMap.Label(tt.answer)
+			require.Equal(t, tt.expectedLabel, label)
+			require.Equal(t, tt.expectedMatched, matched)
+		})
+	}
+
+	var nilMap *AnswerMap
+	label, matched := nilMap.Label("192.0.2.1")
+	require.Equal(t, "192.0.2.1", label)
+	require.True(t, matched)
+}
+
+func TestParseAnswerMapInvalidPattern(t *testing.T) {
+	t.Parallel()
+
+	_, err := ParseAnswerMap(map[string]string{"bad": "[.example.com"})
+	require.NotNil(t, err)
+}
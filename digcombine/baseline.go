@@ -0,0 +1,57 @@
+package digcombine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"go.bbkane.com/shovel/baseline"
+	"go.bbkane.com/shovel/dig"
+)
+
+func printBaselineChanges(t table.Writer, changes []baseline.Change) {
+	//nolint:exhaustruct
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{Name: "Combination", AutoMerge: true},
+	})
+	t.AppendHeader(table.Row{"Combination", "Change", "Ans/Err", "Before", "After"})
+	for _, c := range changes {
+		t.AppendRow(table.Row{c.Key.String(), string(c.Kind), c.Entry, c.Before, c.After})
+	}
+}
+
+// compareAndSaveBaseline compares results to --compare-baseline, then saves them to --save-baseline.
+// It returns an error if anything changed since the baseline so the exit code is non-zero.
+func compareAndSaveBaseline(parsed parsedCmdCtx, results []dig.DigRepeatResult) error {
+	if parsed.CompareBaseline == nil && parsed.SaveBaselinePath == "" {
+		return nil
+	}
+
+	current := baseline.New(time.Now().Round(0).UTC(), parsed.ShovelVersion, parsed.DigRepeatParams, results)
+
+	var changes []baseline.Change
+	if parsed.CompareBaseline != nil {
+		changes = baseline.Compare(*parsed.CompareBaseline, current, parsed.BaselineTolerance)
+		if len(changes) == 0 {
+			fmt.Fprintf(parsed.Stdout, "No changes since the baseline from %s\n", parsed.CompareBaseline.Created.Format(time.RFC3339))
+		} else {
+			t := table.NewWriter()
+			t.SetStyle(table.StyleRounded)
+			t.SetOutputMirror(parsed.Stdout)
+			t.SetTitle("Changes since the baseline from " + parsed.CompareBaseline.Created.Format(time.RFC3339))
+			printBaselineChanges(t, changes)
+			t.Render()
+		}
+	}
+
+	if parsed.SaveBaselinePath != "" {
+		if err := baseline.Save(parsed.SaveBaselinePath, current); err != nil {
+			return err
+		}
+	}
+
+	if len(changes) > 0 {
+		return fmt.Errorf("%d changes since the baseline", len(changes))
+	}
+	return nil
+}
@@ -12,8 +12,14 @@ import (
 	"time"
 
 	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
 	"github.com/miekg/dns"
+	"github.com/sourcegraph/conc/iter"
+	"go.bbkane.com/shovel/baseline"
 	"go.bbkane.com/shovel/dig"
+	"go.bbkane.com/shovel/expect"
+	"go.bbkane.com/shovel/geoip"
+	"go.bbkane.com/warg/path"
 	"go.bbkane.com/warg/wargcore"
 )
 
@@ -32,12 +38,53 @@ func validateNameserverStr(nameserverStr string) error {
 }
 
 type parsedCmdCtx struct {
+	// AnswerMap labels answers. nil if --answer-map isn't passed
+	AnswerMap *AnswerMap
+	// BaselineTolerance is how many percentage points an answer's share can move before it's reported as changed
+	BaselineTolerance float64
+	CacheSnoop        bool
+	// CompareBaseline to report changes against. nil if --compare-baseline isn't passed
+	CompareBaseline *baseline.File
 	Dig             dig.DigOneFunc
 	DigRepeatParams []dig.DigRepeatParams
-	GlobalTimeout   time.Duration
+	// Expectations to check results against. nil if --expect isn't passed
+	Expectations *expect.File
+	// GeoIP annotates subnets and answers. nil if --geoip-db isn't passed
+	GeoIP         *geoip.DB
+	GlobalTimeout time.Duration
+	// GroupNameservers prints the consensus and outliers of each qname/rtype/subnet instead of every combination
+	GroupNameservers bool
+	// Interval to print answers over time in. 0 if --interval isn't passed
+	Interval time.Duration
+	// IntervalFormat is how to print answers over time: sparkline, table or json
+	IntervalFormat string
+	// JUnitPath to write expectation results to. Empty if --junit isn't passed
+	JUnitPath       string
 	NameserverNames map[string]string
-	Stdout          *os.File
-	SubnetToName    map[string]string
+	// OnlyDisagreements filters output to qname/rtype/subnets where nameservers disagree
+	OnlyDisagreements bool
+	// OrderedAnswers is true if answers are kept in the order nameservers returned them
+	OrderedAnswers bool
+	PTRConfirm     bool
+	QnameToIP      map[string]string
+	// QnameWeights maps qnames to expected answer weights
+	QnameWeights map[string]map[string]float64
+	// SaveBaselinePath to save results to. Empty if --save-baseline isn't passed
+	SaveBaselinePath string
+	// ShovelVersion is saved in baselines
+	ShovelVersion string
+	// ShowElements prints how often each individual answer appeared
+	ShowElements bool
+	ShowLatency  bool
+	ShowTTL      bool
+	Stdout       *os.File
+	SubnetToName map[string]string
+	// SweepParents maps swept subnet blocks to the subnet they were swept from
+	SweepParents map[netip.Prefix]netip.Prefix
+	// Watch redigs on this interval. 0 if --watch isn't passed
+	Watch time.Duration
+	// WatchTimelineLength is how many of the latest --watch timeline events to show. 0 shows them all
+	WatchTimelineLength int
 }
 
 func ConvertRTypes(rtypeStrs []string) ([]uint16, error) {
@@ -52,11 +99,18 @@ func ConvertRTypes(rtypeStrs []string) ([]uint16, error) {
 	return rtypes, nil
 }
 
-// ParseSubnets turns a list of passed subnets into a list of net.IP for digging,
-// a map of stringified subnet to name, and an error.
+// hostPrefix converts an IP to a full length prefix
+func hostPrefix(ip net.IP) netip.Prefix {
+	addr, _ := netip.AddrFromSlice(ip)
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen())
+}
+
+// ParseSubnets turns a list of passed subnets into a list of netip.Prefix for digging,
+// a map of stringified subnet (see dig.SubnetString) to name, and an error.
 // It uses the following rules:
 //
-//   - If passedSubnets is empty, returns []net.IP{nil}. Return this instead of nil directly because we'll want to range over the returned list
+//   - If passedSubnets is empty, returns []netip.Prefix{{}}. Return this instead of nil directly because we'll want to range over the returned list
 //
 //   - If passedSubnets == {"all"} and we have a non-empty subnetMap, return everything in subnetMap.
 //
@@ -66,34 +120,36 @@ func ConvertRTypes(rtypeStrs []string) ([]uint16, error) {
 //
 //   - then try to lookup up the passed subnet in subnetMap,
 //
+//   - then try to parse as a CIDR (to send as a partial client subnet or sweep with SweepSubnets),
+//
 //   - then try to parse as an IP.
 //
-// Fail if we can't find it in the map or parse it as an IP.
-func ParseSubnets(passedSubnets []string, subnetMap map[string]net.IP) ([]net.IP, map[string]string, error) {
+// Fail if we can't find it in the map or parse it as a CIDR or IP.
+func ParseSubnets(passedSubnets []string, subnetMap map[string]net.IP) ([]netip.Prefix, map[string]string, error) {
 
-	// no subnets -> {nil}
+	// no subnets -> {invalid prefix}
 	if len(passedSubnets) == 0 {
-		return []net.IP{nil}, nil, nil
+		return []netip.Prefix{{}}, nil, nil
 	}
 	// if "all" is the only thing passed, add everything from subnetMap
 	if len(passedSubnets) == 1 && passedSubnets[0] == "all" && len(subnetMap) > 0 {
-		parsed := []net.IP{}
+		parsed := []netip.Prefix{}
 		subnetToName := make(map[string]string)
 		for name, ip := range subnetMap {
-			parsed = append(parsed, ip)
+			parsed = append(parsed, hostPrefix(ip))
 			subnetToName[ip.String()] = name
 		}
 		return parsed, subnetToName, nil
 	}
 
 	// Loop through passed and try to parse
-	parsed := []net.IP{}
+	parsed := []netip.Prefix{}
 	subnetToName := make(map[string]string)
 	for _, passed := range passedSubnets {
 
 		// check for "none"
 		if passed == "none" {
-			parsed = append(parsed, nil)
+			parsed = append(parsed, netip.Prefix{})
 			// net.IP(nil).String() == "<nil>"
 			subnetToName["<nil>"] = "none"
 			continue
@@ -101,18 +157,31 @@ func ParseSubnets(passedSubnets []string, subnetMap map[string]net.IP) ([]net.IP
 
 		// try to retrieve from map
 		if subIP, exists := subnetMap[passed]; exists {
-			parsed = append(parsed, subIP)
+			parsed = append(parsed, hostPrefix(subIP))
 			subnetToName[subIP.String()] = passed
 			continue
 		}
 
+		// try to parse as CIDR
+		if strings.Contains(passed, "/") {
+			prefix, err := netip.ParsePrefix(passed)
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not parse CIDR: %s", passed)
+			}
+			prefix = prefix.Masked()
+			parsed = append(parsed, prefix)
+			subnetToName[dig.SubnetString(net.IP(prefix.Addr().AsSlice()), prefix.Bits())] = "passed cidr"
+			continue
+		}
+
 		// try to parse as IP
 		subIP := net.ParseIP(passed)
 		if subIP == nil {
 			return nil, nil, fmt.Errorf("could not parse IP: %s", passed)
 		}
-		parsed = append(parsed, subIP)
-		subnetToName[passed] = "passed ip"
+		subPrefix := hostPrefix(subIP)
+		parsed = append(parsed, subPrefix)
+		subnetToName[subPrefix.Addr().String()] = "passed ip"
 	}
 	return parsed, subnetToName, nil
 }
@@ -155,13 +224,57 @@ func ParseNameservers(passedNameservers []string, nameserverMap map[string]strin
 	return nameservers, nameserverNames, nil
 }
 
+// ParseNameserverTsigKeys parses named TSIG keys from tsigKeyMap (key name to <algorithm>:<base64 secret>)
+// and returns a map of nameserver IP:port to the key it should be queried with.
+// nameserverTsigMap maps nameserver names (as passed to ParseNameservers) or IP:ports to key names.
+// nameserverNames is the nameserver IP:port to name map returned by ParseNameservers.
+func ParseNameserverTsigKeys(tsigKeyMap map[string]string, nameserverTsigMap map[string]string, nameserverNames map[string]string) (map[string]*dig.TsigKey, error) {
+	keys := make(map[string]dig.TsigKey)
+	for name, value := range tsigKeyMap {
+		key, err := dig.ParseTsigKey(name, value)
+		if err != nil {
+			return nil, err
+		}
+		keys[name] = key
+	}
+
+	nameserverToKey := make(map[string]*dig.TsigKey)
+	for nameserver, nsName := range nameserverNames {
+		keyName, exists := nameserverTsigMap[nsName]
+		if !exists {
+			keyName, exists = nameserverTsigMap[nameserver]
+		}
+		if !exists {
+			continue
+		}
+		key, exists := keys[keyName]
+		if !exists {
+			return nil, fmt.Errorf("unknown tsig key for nameserver %s: %s", nameserver, keyName)
+		}
+		nameserverToKey[nameserver] = &key
+	}
+	return nameserverToKey, nil
+}
+
 func parseCmdCtx(cmdCtx wargcore.Context) (*parsedCmdCtx, error) {
 
 	// simple params
 	count := cmdCtx.Flags["--count"].(int)
-	qnames := cmdCtx.Flags["--qname"].([]string)
+	maxCombinations := cmdCtx.Flags["--max-combinations"].(int)
 	globalTimeout := cmdCtx.Flags["--global-timeout"].(time.Duration)
 	proto := cmdCtx.Flags["--protocol"].(string)
+	showLatency, _ := cmdCtx.Flags["--show-latency"].(bool)
+	showTTL, _ := cmdCtx.Flags["--show-ttl"].(bool)
+	cacheSnoop, _ := cmdCtx.Flags["--cache-snoop"].(bool)
+	orderedAnswers, _ := cmdCtx.Flags["--ordered-answers"].(bool)
+	showElements, _ := cmdCtx.Flags["--elements"].(bool)
+	// the remaining TTL is the point of snooping
+	showTTL = showTTL || cacheSnoop
+
+	qnames, err := ExpandQnames(cmdCtx.Flags["--qname"].([]string), os.ReadFile, os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't expand qnames: %w", err)
+	}
 
 	// rtypes
 	rtypeStrs := cmdCtx.Flags["--rtype"].([]string)
@@ -170,6 +283,17 @@ func parseCmdCtx(cmdCtx wargcore.Context) (*parsedCmdCtx, error) {
 		return nil, fmt.Errorf("couldn't parse cmdCtx: %w", err)
 	}
 
+	qnames, qnameToIP, err := ReverseQnames(qnames, rtypes)
+	if err != nil {
+		return nil, err
+	}
+	ptrConfirm, _ := cmdCtx.Flags["--ptr-confirm"].(bool)
+	for _, qname := range qnames {
+		if err := dig.ValidateQnameTemplate(qname); err != nil {
+			return nil, err
+		}
+	}
+
 	var digOneFunc dig.DigOneFunc = dig.DigOne
 	if replacementDigOneFunc := cmdCtx.Context.Value(dig.DigOneFuncCtxKey{}); replacementDigOneFunc != nil {
 		digOneFunc = replacementDigOneFunc.(dig.DigOneFunc)
@@ -188,6 +312,11 @@ func parseCmdCtx(cmdCtx wargcore.Context) (*parsedCmdCtx, error) {
 	if err != nil {
 		return nil, fmt.Errorf("couldn't parse subnets: %w", err)
 	}
+	subnetStep, _ := cmdCtx.Flags["--subnet-step"].(int)
+	parsedSubnets, sweepParents, err := SweepSubnets(parsedSubnets, subnetToName, subnetStep)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't sweep subnets: %w", err)
+	}
 
 	// NOTE: if the wrong types are asserted, the resulting map is nil...
 	// It would be nice if Go was kind enough to panic...
@@ -200,6 +329,11 @@ func parseCmdCtx(cmdCtx wargcore.Context) (*parsedCmdCtx, error) {
 		return nil, err
 	}
 
+	err = CheckCombinationCount(len(qnames), len(rtypes), len(parsedSubnets), len(parsedNameservers), maxCombinations)
+	if err != nil {
+		return nil, err
+	}
+
 	digRepeatParamsSlice := dig.CombineDigRepeatParams(
 		parsedNameservers,
 		proto,
@@ -209,29 +343,230 @@ func parseCmdCtx(cmdCtx wargcore.Context) (*parsedCmdCtx, error) {
 		count,
 	)
 
+	var compareBaseline *baseline.File
+	if compareBaselinePath, exists := cmdCtx.Flags["--compare-baseline"].(path.Path); exists {
+		compareBaseline, err = baseline.Load(compareBaselinePath.MustExpand())
+		if err != nil {
+			return nil, err
+		}
+		// rerun the baseline's matrix so a flag typo can't pass for a regression
+		digRepeatParamsSlice, err = compareBaseline.Params()
+		if err != nil {
+			return nil, err
+		}
+		sweepParents = nil
+	}
+
 	if len(digRepeatParamsSlice) < 1 {
 		return nil, errors.New("no dig parameters passed")
 	}
 
+	// tsig
+	tsigKeyMap, _ := cmdCtx.Flags["--tsig-key-map"].(map[string]string)
+	nameserverTsigMap, _ := cmdCtx.Flags["--nameserver-tsig-map"].(map[string]string)
+	nameserverToTsigKey, err := ParseNameserverTsigKeys(tsigKeyMap, nameserverTsigMap, nameserverToName)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse tsig keys: %w", err)
+	}
+	for i := range digRepeatParamsSlice {
+		p := &digRepeatParamsSlice[i].DigOneParams
+		tsig := nameserverToTsigKey[p.NameserverIPPort]
+		if compareBaseline != nil {
+			// keep the baseline's settings, filling in the TSIG secret it doesn't save
+			if p.Tsig != nil {
+				if tsig == nil || tsig.Name != p.Tsig.Name || tsig.Algorithm != p.Tsig.Algorithm {
+					return nil, fmt.Errorf("baseline combination %s was signed with TSIG key %s. Pass it with --tsig-key-map and --nameserver-tsig-map", baseline.KeyFor(*p), p.Tsig.Name)
+				}
+				p.Tsig = tsig
+			}
+			continue
+		}
+		p.Tsig = tsig
+		p.NoRecurse = cacheSnoop
+		p.OrderedAnswers = orderedAnswers
+	}
+
+	nameToAnswer, _ := cmdCtx.Flags["--answer-map"].(map[string]string)
+	var answerMap *AnswerMap
+	if len(nameToAnswer) > 0 {
+		answerMap, err = ParseAnswerMap(nameToAnswer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// weights are keyed by qname as displayed, so IPs for reverse lookups
+	displayQnames := []string{}
+	for _, qname := range qnames {
+		if ip, isIP := qnameToIP[qname]; isIP {
+			qname = ip
+		}
+		displayQnames = append(displayQnames, qname)
+	}
+	qnameToWeights, _ := cmdCtx.Flags["--weights"].(map[string]string)
+	qnameWeights, err := ParseQnameWeights(qnameToWeights, displayQnames)
+	if err != nil {
+		return nil, err
+	}
+
+	watchInterval, _ := cmdCtx.Flags["--watch"].(time.Duration)
+	watchTimelineLength, _ := cmdCtx.Flags["--watch-timeline-length"].(int)
+	if watchTimelineLength < 0 {
+		return nil, errors.New("--watch-timeline-length can't be negative")
+	}
+	interval, _ := cmdCtx.Flags["--interval"].(time.Duration)
+	intervalFormat, _ := cmdCtx.Flags["--interval-format"].(string)
+	if interval < 0 {
+		return nil, errors.New("--interval can't be negative")
+	}
+	if interval > 0 && watchInterval > 0 && intervalFormat == "json" {
+		return nil, errors.New("--interval-format json can't be used with --watch")
+	}
+	// --watch buckets whole rounds itself, so windows span rounds
+	if watchInterval == 0 {
+		for i := range digRepeatParamsSlice {
+			digRepeatParamsSlice[i].Interval = interval
+		}
+	}
+	groupNameservers, _ := cmdCtx.Flags["--group-nameservers"].(bool)
+	onlyDisagreements, _ := cmdCtx.Flags["--only-disagreements"].(bool)
+
+	saveBaselinePath := ""
+	if saveBaselinePathFlag, exists := cmdCtx.Flags["--save-baseline"].(path.Path); exists {
+		saveBaselinePath = saveBaselinePathFlag.MustExpand()
+	}
+	if watchInterval > 0 && (compareBaseline != nil || saveBaselinePath != "") {
+		return nil, errors.New("baselines can't be used with --watch")
+	}
+	baselineTolerance, _ := cmdCtx.Flags["--baseline-tolerance"].(int)
+
+	var expectations *expect.File
+	if expectPath, exists := cmdCtx.Flags["--expect"].(path.Path); exists {
+		if watchInterval > 0 {
+			return nil, errors.New("--expect can't be used with --watch")
+		}
+		expectations, err = expect.Load(expectPath.MustExpand())
+		if err != nil {
+			return nil, err
+		}
+	}
+	junitPath := ""
+	if junitPathFlag, exists := cmdCtx.Flags["--junit"].(path.Path); exists {
+		if expectations == nil {
+			return nil, errors.New("--junit requires --expect")
+		}
+		junitPath = junitPathFlag.MustExpand()
+	}
+
+	var geoIPDB *geoip.DB
+	if geoIPPath, exists := cmdCtx.Flags["--geoip-db"].(path.Path); exists {
+		geoIPDB, err = geoip.Open(geoIPPath.MustExpand())
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &parsedCmdCtx{
-		Dig:             digOneFunc,
-		DigRepeatParams: digRepeatParamsSlice,
-		GlobalTimeout:   globalTimeout,
-		NameserverNames: nameserverToName,
-		Stdout:          cmdCtx.Stdout,
-		SubnetToName:    subnetToName,
+		AnswerMap:           answerMap,
+		BaselineTolerance:   float64(baselineTolerance),
+		CacheSnoop:          cacheSnoop,
+		CompareBaseline:     compareBaseline,
+		Dig:                 digOneFunc,
+		DigRepeatParams:     digRepeatParamsSlice,
+		Expectations:        expectations,
+		GeoIP:               geoIPDB,
+		GlobalTimeout:       globalTimeout,
+		GroupNameservers:    groupNameservers,
+		Interval:            interval,
+		IntervalFormat:      intervalFormat,
+		JUnitPath:           junitPath,
+		NameserverNames:     nameserverToName,
+		OnlyDisagreements:   onlyDisagreements,
+		OrderedAnswers:      orderedAnswers,
+		PTRConfirm:          ptrConfirm,
+		QnameToIP:           qnameToIP,
+		QnameWeights:        qnameWeights,
+		SaveBaselinePath:    saveBaselinePath,
+		ShovelVersion:       cmdCtx.App.Version,
+		ShowElements:        showElements,
+		ShowLatency:         showLatency,
+		ShowTTL:             showTTL,
+		Stdout:              cmdCtx.Stdout,
+		SubnetToName:        subnetToName,
+		SweepParents:        sweepParents,
+		Watch:               watchInterval,
+		WatchTimelineLength: watchTimelineLength,
 	}, nil
 }
 
-func printDigRepeat(t table.Writer, parsed parsedCmdCtx, p dig.DigRepeatParams, r dig.DigRepeatResult) {
+// forwardConfirm forward confirms the PTR answers of each combination with an IP qname.
+// The returned slice is indexed by combination, then answer.
+func forwardConfirm(ctx context.Context, parsed parsedCmdCtx, results []dig.DigRepeatResult) [][][]dig.ForwardConfirmation {
+	forward := make([][][]dig.ForwardConfirmation, len(parsed.DigRepeatParams))
+	iter.ForEachIdx(parsed.DigRepeatParams, func(i int, p *dig.DigRepeatParams) {
+		ipStr, isIP := parsed.QnameToIP[p.DigOneParams.Qname]
+		if p.DigOneParams.Rtype != dns.TypePTR || !isIP {
+			return
+		}
+		ip := netip.MustParseAddr(ipStr)
+		for _, ans := range results[i].Answers {
+			forward[i] = append(forward[i], dig.ForwardConfirm(ctx, parsed.Dig, p.DigOneParams, ip, ans.StringSlice))
+		}
+	})
+	return forward
+}
+
+func fmtForwardConfirmations(confirmations []dig.ForwardConfirmation) string {
+	lines := []string{}
+	for _, c := range confirmations {
+		switch {
+		case c.Err != nil:
+			lines = append(lines, "ERROR: "+c.Target+": "+c.Err.Error())
+		case c.Confirmed:
+			lines = append(lines, "ok: "+c.Target)
+		default:
+			lines = append(lines, "MISMATCH: "+c.Target+" -> "+strings.Join(c.Addrs, ", "))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func printDigRepeat(t table.Writer, parsed parsedCmdCtx, p dig.DigRepeatParams, r dig.DigRepeatResult, forward [][]dig.ForwardConfirmation) {
 
-	fmtSubnet := func(subnet net.IP) string {
+	fmtQname := func(qname string) string {
+		if ip, isIP := parsed.QnameToIP[qname]; isIP {
+			return ip
+		}
+		return qname
+	}
+
+	fmtSubnet := func(subnet net.IP, bits int) string {
 		if subnet == nil {
 			return ""
 		}
-		subnetStr := subnet.String()
+		subnetStr := dig.SubnetString(subnet, bits)
 		name := parsed.SubnetToName[subnetStr]
-		return "# " + name + "\n" + subnet.String()
+		ret := "# " + name + "\n" + subnetStr
+		if annotation := parsed.GeoIP.Annotate(subnet.String()); annotation != "" {
+			ret += "\n# " + annotation
+		}
+		return ret
+	}
+
+	// fmtAnswers labels answers and highlights the ones --answer-map doesn't know about
+	fmtAnswers := func(answers []string) string {
+		lines := make([]string, 0, len(answers))
+		for _, ans := range answers {
+			label, matched := parsed.AnswerMap.Label(ans)
+			if annotation := parsed.GeoIP.Annotate(ans); annotation != "" {
+				label += " (" + annotation + ")"
+			}
+			if !matched {
+				label = text.FgHiRed.Sprint(label)
+			}
+			lines = append(lines, label)
+		}
+		return strings.Join(lines, "\n")
 	}
 
 	fmtNS := func(ns string) string {
@@ -240,24 +575,40 @@ func printDigRepeat(t table.Writer, parsed parsedCmdCtx, p dig.DigRepeatParams,
 	}
 
 	// answers
-	for _, ans := range r.Answers {
+	for i, ans := range r.Answers {
+		forwardStr := ""
+		if len(forward) > i {
+			forwardStr = fmtForwardConfirmations(forward[i])
+		}
 		t.AppendRow(table.Row{
-			p.DigOneParams.Qname,
+			fmtQname(p.DigOneParams.Qname),
 			dns.TypeToString[p.DigOneParams.Rtype],
-			fmtSubnet(p.DigOneParams.SubnetIP),
+			fmtSubnet(p.DigOneParams.SubnetIP, p.DigOneParams.SubnetBits),
 			fmtNS(p.DigOneParams.NameserverIPPort),
-			strings.Join(ans.StringSlice, "\n"),
+			r.Latency.String(),
+			"yes",
+			fmtAnswers(ans.StringSlice),
+			forwardStr,
+			r.AnswerTTLs[i].String(),
 			ans.Count,
 		})
 	}
 	// errors
 	for _, err := range r.Errors {
+		cached := ""
+		if err.Category == dig.ErrorNotCached {
+			cached = "no"
+		}
 		t.AppendRow(table.Row{
-			p.DigOneParams.Qname,
+			fmtQname(p.DigOneParams.Qname),
 			dns.TypeToString[p.DigOneParams.Rtype],
-			fmtSubnet(p.DigOneParams.SubnetIP),
+			fmtSubnet(p.DigOneParams.SubnetIP, p.DigOneParams.SubnetBits),
 			fmtNS(p.DigOneParams.NameserverIPPort),
-			err.String,
+			r.Latency.String(),
+			cached,
+			strings.Join(err.Lines(), "\n"),
+			"",
+			"",
 			err.Count,
 		})
 	}
@@ -266,22 +617,8 @@ func printDigRepeat(t table.Writer, parsed parsedCmdCtx, p dig.DigRepeatParams,
 
 }
 
-func Run(cmdCtx wargcore.Context) error {
-
-	parsed, err := parseCmdCtx(cmdCtx)
-	if err != nil {
-		return err
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), parsed.GlobalTimeout)
-	defer cancel()
-
-	results := dig.DigRepeatParallel(ctx, parsed.DigRepeatParams, parsed.Dig)
-
-	t := table.NewWriter()
-	t.SetStyle(table.StyleRounded)
-	t.SetOutputMirror(parsed.Stdout)
-
+// printResultsTable prints a row per answer set and error of each combination that passes shown
+func printResultsTable(ctx context.Context, t table.Writer, parsed parsedCmdCtx, results []dig.DigRepeatResult, shown func(i int) bool) {
 	// due to the way parsing works, if the first subnet is nil,
 	// we can assume the rest are too. If so, hide the subnet column
 	hideSubnets := parsed.DigRepeatParams[0].DigOneParams.SubnetIP == nil
@@ -296,19 +633,116 @@ func Run(cmdCtx wargcore.Context) error {
 		{Name: "Rtype", AutoMerge: true},
 		{Name: "Subnet", AutoMerge: true, Hidden: hideSubnets},
 		{Name: "Nameserver", AutoMerge: true},
+		{Name: "Latency", AutoMerge: true, Hidden: !parsed.ShowLatency},
+		{Name: "Cached", Hidden: !parsed.CacheSnoop},
 		{Name: "Ans/Err"},
+		{Name: "Forward", Hidden: !parsed.PTRConfirm},
+		{Name: "TTL", Hidden: !parsed.ShowTTL},
 		{Name: "Count", Hidden: hideCount},
 	}
 
 	t.SetColumnConfigs(columnConfigs)
 
-	t.AppendHeader(table.Row{"Qname", "Rtype", "Subnet", "Nameserver", "Ans/Err", "Count"})
+	t.AppendHeader(table.Row{"Qname", "Rtype", "Subnet", "Nameserver", "Latency", "Cached", "Ans/Err", "Forward", "TTL", "Count"})
+
+	var forward [][][]dig.ForwardConfirmation
+	if parsed.PTRConfirm {
+		forward = forwardConfirm(ctx, parsed, results)
+	}
 
 	for i := 0; i < len(parsed.DigRepeatParams); i++ {
-		printDigRepeat(t, *parsed, parsed.DigRepeatParams[i], results[i])
+		if !shown(i) {
+			continue
+		}
+		var f [][]dig.ForwardConfirmation
+		if forward != nil {
+			f = forward[i]
+		}
+		printDigRepeat(t, parsed, parsed.DigRepeatParams[i], results[i], f)
 	}
+}
 
-	t.Render()
+// printResults prints the results table, the collapsed ranges when sweeping subnets, or the nameserver groups,
+// followed by any answer element stats and weight checks
+func printResults(ctx context.Context, parsed parsedCmdCtx, results []dig.DigRepeatResult) {
+	var groups []NameserverGroup
+	var disagreeing map[string]bool
+	if parsed.GroupNameservers || parsed.OnlyDisagreements {
+		groups = GroupNameservers(parsed.DigRepeatParams, results)
+		disagreeing = disagreeingGroups(groups)
+	}
+	// shown reports whether combination i passes --only-disagreements
+	shown := func(i int) bool {
+		return !parsed.OnlyDisagreements || disagreeing[digName(parsed.DigRepeatParams[i].DigOneParams, nil, false)]
+	}
+	allAgree := parsed.OnlyDisagreements && len(disagreeing) == 0
 
-	return nil
+	t := table.NewWriter()
+	t.SetStyle(table.StyleRounded)
+	t.SetOutputMirror(parsed.Stdout)
+	switch {
+	case len(parsed.SweepParents) > 0:
+		printSweepRanges(t, parsed, CollapseSweep(parsed.DigRepeatParams, results, parsed.SweepParents))
+		t.Render()
+	case allAgree:
+		fmt.Fprintln(parsed.Stdout, "All nameservers agree")
+	case parsed.GroupNameservers:
+		printNameserverGroups(t, parsed, groups)
+		t.Render()
+	default:
+		printResultsTable(ctx, t, parsed, results, shown)
+		t.Render()
+	}
+
+	if parsed.ShowElements && !allAgree {
+		et := table.NewWriter()
+		et.SetStyle(table.StyleRounded)
+		et.SetOutputMirror(parsed.Stdout)
+		printElementStats(et, parsed, results, shown)
+		et.Render()
+	}
+
+	if len(parsed.QnameWeights) > 0 {
+		checks := CheckWeights(parsed.DigRepeatParams, results, parsed.AnswerMap, parsed.QnameToIP, parsed.QnameWeights)
+		wt := table.NewWriter()
+		wt.SetStyle(table.StyleRounded)
+		wt.SetOutputMirror(parsed.Stdout)
+		printWeightChecks(wt, parsed, checks)
+		wt.Render()
+	}
+}
+
+func Run(cmdCtx wargcore.Context) error {
+
+	parsed, err := parseCmdCtx(cmdCtx)
+	if err != nil {
+		return err
+	}
+	defer parsed.GeoIP.Close()
+
+	if parsed.Watch > 0 {
+		return watch(*parsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), parsed.GlobalTimeout)
+	defer cancel()
+
+	start := time.Now()
+	results := dig.DigRepeatParallel(ctx, parsed.DigRepeatParams, parsed.Dig)
+	elapsed := time.Since(start)
+
+	// JSON output is only useful if it's the only output
+	if parsed.Interval == 0 || parsed.IntervalFormat != "json" {
+		printResults(ctx, *parsed, results)
+	}
+	if parsed.Interval > 0 {
+		if err := printAnswersOverTime(*parsed, results); err != nil {
+			return err
+		}
+	}
+
+	return errors.Join(
+		compareAndSaveBaseline(*parsed, results),
+		checkExpectations(*parsed, results, elapsed),
+	)
 }
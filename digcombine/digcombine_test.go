@@ -1,10 +1,16 @@
 package digcombine
 
 import (
+	"context"
 	"net"
+	"net/netip"
+	"os"
 	"testing"
 
+	"github.com/miekg/dns"
 	"github.com/stretchr/testify/require"
+	"go.bbkane.com/shovel/counter"
+	"go.bbkane.com/shovel/dig"
 )
 
 func TestParseNameservers(t *testing.T) {
@@ -79,7 +85,7 @@ func TestParseSubnets(t *testing.T) {
 		name                string
 		passedSubnets       []string
 		subnetMap           map[string]net.IP
-		expectedSubnets     []net.IP
+		expectedSubnets     []netip.Prefix
 		expectedSubnetNames map[string]string
 		expectedErr         bool
 	}{
@@ -87,7 +93,7 @@ func TestParseSubnets(t *testing.T) {
 			name:                "noSubnet",
 			passedSubnets:       nil,
 			subnetMap:           nil,
-			expectedSubnets:     []net.IP{nil},
+			expectedSubnets:     []netip.Prefix{{}},
 			expectedSubnetNames: nil,
 			expectedErr:         false,
 		},
@@ -95,7 +101,7 @@ func TestParseSubnets(t *testing.T) {
 			name:                "subnetPassedAsArg",
 			passedSubnets:       []string{"1.2.3.0"},
 			subnetMap:           nil,
-			expectedSubnets:     []net.IP{net.ParseIP("1.2.3.0")},
+			expectedSubnets:     []netip.Prefix{netip.MustParsePrefix("1.2.3.0/32")},
 			expectedSubnetNames: map[string]string{"1.2.3.0": "passed ip"},
 			expectedErr:         false,
 		},
@@ -111,7 +117,7 @@ func TestParseSubnets(t *testing.T) {
 			name:                "subnetFromMap",
 			passedSubnets:       []string{"mysubnet"},
 			subnetMap:           map[string]net.IP{"mysubnet": net.ParseIP("3.4.5.0")},
-			expectedSubnets:     []net.IP{net.ParseIP("3.4.5.0")},
+			expectedSubnets:     []netip.Prefix{netip.MustParsePrefix("3.4.5.0/32")},
 			expectedSubnetNames: map[string]string{"3.4.5.0": "mysubnet"},
 			expectedErr:         false,
 		},
@@ -119,15 +125,31 @@ func TestParseSubnets(t *testing.T) {
 			name:                "subnetAll",
 			passedSubnets:       []string{"all"},
 			subnetMap:           map[string]net.IP{"subnetName": net.ParseIP("1.1.1.0")},
-			expectedSubnets:     []net.IP{net.ParseIP("1.1.1.0")},
+			expectedSubnets:     []netip.Prefix{netip.MustParsePrefix("1.1.1.0/32")},
 			expectedSubnetNames: map[string]string{"1.1.1.0": "subnetName"},
 			expectedErr:         false,
 		},
+		{
+			name:                "subnetCIDR",
+			passedSubnets:       []string{"10.1.2.3/16"},
+			subnetMap:           nil,
+			expectedSubnets:     []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")},
+			expectedSubnetNames: map[string]string{"10.1.0.0/16": "passed cidr"},
+			expectedErr:         false,
+		},
+		{
+			name:                "badCIDR",
+			passedSubnets:       []string{"10.0.0.0/33"},
+			subnetMap:           nil,
+			expectedSubnets:     nil,
+			expectedSubnetNames: nil,
+			expectedErr:         true,
+		},
 		{
 			name:                "subnetNone",
 			passedSubnets:       nil,
 			subnetMap:           nil,
-			expectedSubnets:     []net.IP{nil},
+			expectedSubnets:     []netip.Prefix{{}},
 			expectedSubnetNames: nil,
 			expectedErr:         false,
 		},
@@ -146,3 +168,168 @@ func TestParseSubnets(t *testing.T) {
 		})
 	}
 }
+
+func TestParseNameserverTsigKeys(t *testing.T) {
+	t.Parallel()
+
+	key := dig.TsigKey{
+		Name:      "mykey.",
+		Algorithm: dns.HmacSHA256,
+		Secret:    "c2VjcmV0",
+	}
+
+	tests := []struct {
+		name              string
+		tsigKeyMap        map[string]string
+		nameserverTsigMap map[string]string
+		nameserverNames   map[string]string
+		expected          map[string]*dig.TsigKey
+		expectedErr       bool
+	}{
+		{
+			name:              "byNameserverName",
+			tsigKeyMap:        map[string]string{"mykey": "hmac-sha256:c2VjcmV0"},
+			nameserverTsigMap: map[string]string{"internal": "mykey"},
+			nameserverNames:   map[string]string{"10.0.0.1:53": "internal", "8.8.8.8:53": "google"},
+			expected:          map[string]*dig.TsigKey{"10.0.0.1:53": &key},
+			expectedErr:       false,
+		},
+		{
+			name:              "byIPPort",
+			tsigKeyMap:        map[string]string{"mykey": "HMAC-SHA256:c2VjcmV0"},
+			nameserverTsigMap: map[string]string{"10.0.0.1:53": "mykey"},
+			nameserverNames:   map[string]string{"10.0.0.1:53": "passed ns:port"},
+			expected:          map[string]*dig.TsigKey{"10.0.0.1:53": &key},
+			expectedErr:       false,
+		},
+		{
+			name:              "unknownKey",
+			tsigKeyMap:        nil,
+			nameserverTsigMap: map[string]string{"internal": "mykey"},
+			nameserverNames:   map[string]string{"10.0.0.1:53": "internal"},
+			expected:          nil,
+			expectedErr:       true,
+		},
+		{
+			name:              "badAlgorithm",
+			tsigKeyMap:        map[string]string{"mykey": "hmac-sha3:c2VjcmV0"},
+			nameserverTsigMap: nil,
+			nameserverNames:   nil,
+			expected:          nil,
+			expectedErr:       true,
+		},
+		{
+			name:              "badSecret",
+			tsigKeyMap:        map[string]string{"mykey": "hmac-sha256:not base64!"},
+			nameserverTsigMap: nil,
+			nameserverNames:   nil,
+			expected:          nil,
+			expectedErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, actualErr := ParseNameserverTsigKeys(tt.tsigKeyMap, tt.nameserverTsigMap, tt.nameserverNames)
+			if tt.expectedErr {
+				require.NotNil(t, actualErr)
+			} else {
+				require.Nil(t, actualErr)
+			}
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestPrintResultsSections(t *testing.T) {
+	t.Parallel()
+
+	params := dig.CombineDigRepeatParams(
+		[]string{"198.51.100.1:53", "198.51.100.2:53"},
+		"udp",
+		[]string{"www.example.com", "api.example.com"},
+		[]uint16{dns.TypeA},
+		[]netip.Prefix{{}},
+		2,
+	)
+	result := func(answer string) dig.DigRepeatResult {
+		return dig.DigRepeatResult{
+			Answers:         []counter.StringSliceCount{{StringSlice: []string{answer}, Count: 2}},
+			AnswerTTLs:      []dig.TTLStats{{Min: 60, Max: 60, Decreased: false}},
+			Errors:          nil,
+			Latency:         dig.LatencyStats{Min: 0, P50: 0, P90: 0, P99: 0, Max: 0},
+			AnswersOverTime: nil,
+		}
+	}
+	// nameservers agree about www, and disagree about api
+	results := []dig.DigRepeatResult{result("1.1.1.1"), result("1.1.1.1"), result("2.2.2.2"), result("3.3.3.3")}
+
+	tests := []struct {
+		name              string
+		groupNameservers  bool
+		onlyDisagreements bool
+		agree             bool
+		expected          []string
+		notExpected       []string
+	}{
+		{
+			name:              "grouped",
+			groupNameservers:  true,
+			onlyDisagreements: false,
+			agree:             false,
+			expected:          []string{"Answers", "1.1.1.1", "2.2.2.2", "P-VALUE"},
+			notExpected:       nil,
+		},
+		{
+			name:              "onlyDisagreements",
+			groupNameservers:  false,
+			onlyDisagreements: true,
+			agree:             false,
+			expected:          []string{"Answers", "2.2.2.2", "P-VALUE"},
+			notExpected:       []string{"1.1.1.1"},
+		},
+		{
+			name:              "allAgree",
+			groupNameservers:  false,
+			onlyDisagreements: true,
+			agree:             true,
+			expected:          []string{"All nameservers agree", "P-VALUE"},
+			notExpected:       []string{"Answers"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			stdout, err := os.CreateTemp(t.TempDir(), "stdout")
+			require.Nil(t, err)
+			defer stdout.Close()
+
+			res := results
+			if tt.agree {
+				res = []dig.DigRepeatResult{result("1.1.1.1"), result("1.1.1.1"), result("2.2.2.2"), result("2.2.2.2")}
+			}
+			//nolint:exhaustruct
+			parsed := parsedCmdCtx{
+				DigRepeatParams:   params,
+				GroupNameservers:  tt.groupNameservers,
+				OnlyDisagreements: tt.onlyDisagreements,
+				NameserverNames:   map[string]string{"198.51.100.1:53": "ns1", "198.51.100.2:53": "ns2"},
+				QnameWeights:      map[string]map[string]float64{"api.example.com": {"2.2.2.2": 50, "3.3.3.3": 50}},
+				ShowElements:      true,
+				Stdout:            stdout,
+			}
+			printResults(context.Background(), parsed, res)
+
+			out, err := os.ReadFile(stdout.Name())
+			require.Nil(t, err)
+			for _, e := range tt.expected {
+				require.Contains(t, string(out), e)
+			}
+			for _, e := range tt.notExpected {
+				require.NotContains(t, string(out), e)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package digcombine
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/miekg/dns"
+	"go.bbkane.com/shovel/dig"
+)
+
+// Outlier is a nameserver that didn't return the consensus answers
+type Outlier struct {
+	Nameserver string
+	// Signature is the distinct answers it returned, one per line. See answerSignature
+	Signature string
+}
+
+// NameserverErrors are the errors one nameserver hit while digging a group
+type NameserverErrors struct {
+	Nameserver string
+	Errors     []dig.ErrorCount
+}
+
+// answerSignature summarizes the distinct answers in a result, ignoring counts and errors,
+// so a transient timeout doesn't make a nameserver disagree. Results without answers fall back to their error categories
+func answerSignature(r dig.DigRepeatResult) string {
+	if len(r.Answers) == 0 {
+		return resultSignature(r)
+	}
+	lines := []string{}
+	for _, ans := range r.Answers {
+		lines = append(lines, strings.Join(ans.StringSlice, "\n"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// NameserverGroup compares the nameservers dug for one qname/rtype/subnet
+type NameserverGroup struct {
+	// Params of the first combination in the group. Use it for the qname, rtype and subnet
+	Params dig.DigRepeatParams
+	// Consensus is the most common distinct answers, one per line. Ties go to the first nameserver
+	Consensus            string
+	ConsensusNameservers []string
+	Outliers             []Outlier
+	// Errors of every nameserver that hit any, in nameserver order
+	Errors []NameserverErrors
+	// AgreementPercent is the percentage of nameservers returning the consensus
+	AgreementPercent float64
+}
+
+func (g NameserverGroup) Disagree() bool {
+	return len(g.Outliers) > 0
+}
+
+// GroupNameservers groups combinations that only differ by nameserver and finds the consensus answers for each.
+// Groups are in the order they first appear in params.
+func GroupNameservers(params []dig.DigRepeatParams, results []dig.DigRepeatResult) []NameserverGroup {
+	groupOrder := []string{}
+	groupIdxs := make(map[string][]int)
+	for i, p := range params {
+		key := digName(p.DigOneParams, nil, false)
+		if _, exists := groupIdxs[key]; !exists {
+			groupOrder = append(groupOrder, key)
+		}
+		groupIdxs[key] = append(groupIdxs[key], i)
+	}
+
+	ret := make([]NameserverGroup, 0, len(groupOrder))
+	for _, key := range groupOrder {
+		idxs := groupIdxs[key]
+
+		sigCounts := make(map[string]int)
+		sigs := make([]string, len(idxs))
+		for j, i := range idxs {
+			sigs[j] = answerSignature(results[i])
+			sigCounts[sigs[j]]++
+		}
+		// iterate in nameserver order so ties go to the first nameserver
+		consensus := sigs[0]
+		for _, sig := range sigs {
+			if sigCounts[sig] > sigCounts[consensus] {
+				consensus = sig
+			}
+		}
+
+		group := NameserverGroup{
+			Params:               params[idxs[0]],
+			Consensus:            consensus,
+			ConsensusNameservers: []string{},
+			Outliers:             []Outlier{},
+			Errors:               []NameserverErrors{},
+			AgreementPercent:     100 * float64(sigCounts[consensus]) / float64(len(idxs)),
+		}
+		for j, i := range idxs {
+			ns := params[i].DigOneParams.NameserverIPPort
+			if sigs[j] == consensus {
+				group.ConsensusNameservers = append(group.ConsensusNameservers, ns)
+			} else {
+				group.Outliers = append(group.Outliers, Outlier{Nameserver: ns, Signature: sigs[j]})
+			}
+			if len(results[i].Errors) > 0 {
+				group.Errors = append(group.Errors, NameserverErrors{Nameserver: ns, Errors: results[i].Errors})
+			}
+		}
+		ret = append(ret, group)
+	}
+	return ret
+}
+
+// disagreeingGroups returns the names of groups where nameservers disagree
+func disagreeingGroups(groups []NameserverGroup) map[string]bool {
+	ret := make(map[string]bool)
+	for _, g := range groups {
+		if g.Disagree() {
+			ret[digName(g.Params.DigOneParams, nil, false)] = true
+		}
+	}
+	return ret
+}
+
+func printNameserverGroups(t table.Writer, parsed parsedCmdCtx, groups []NameserverGroup) {
+	fmtNS := func(ns string) string {
+		return parsed.NameserverNames[ns] + " (" + ns + ")"
+	}
+
+	//nolint:exhaustruct
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{Name: "Subnet", Hidden: parsed.DigRepeatParams[0].DigOneParams.SubnetIP == nil},
+	})
+	t.AppendHeader(table.Row{"Qname", "Rtype", "Subnet", "Agreement", "Consensus", "Outliers", "Errors"})
+	for _, g := range groups {
+		if parsed.OnlyDisagreements && !g.Disagree() {
+			continue
+		}
+		qname := g.Params.DigOneParams.Qname
+		if ip, isIP := parsed.QnameToIP[qname]; isIP {
+			qname = ip
+		}
+		subnet := ""
+		if g.Params.DigOneParams.SubnetIP != nil {
+			subnet = dig.SubnetString(g.Params.DigOneParams.SubnetIP, g.Params.DigOneParams.SubnetBits)
+		}
+		agreement := strconv.FormatFloat(g.AgreementPercent, 'f', 0, 64) + "%"
+		if g.Disagree() {
+			agreement = text.FgHiRed.Sprint(agreement)
+		}
+		outliers := []string{}
+		for _, o := range g.Outliers {
+			outliers = append(outliers, fmtNS(o.Nameserver)+":\n  "+strings.ReplaceAll(o.Signature, "\n", "\n  "))
+		}
+		errs := []string{}
+		for _, e := range g.Errors {
+			counts := []string{}
+			for _, c := range e.Errors {
+				counts = append(counts, string(c.Category)+" ("+strconv.Itoa(c.Count)+")")
+			}
+			errs = append(errs, fmtNS(e.Nameserver)+":\n  "+strings.Join(counts, "\n  "))
+		}
+		t.AppendRow(table.Row{
+			qname,
+			dns.TypeToString[g.Params.DigOneParams.Rtype],
+			subnet,
+			agreement,
+			g.Consensus,
+			strings.Join(outliers, "\n"),
+			strings.Join(errs, "\n"),
+		})
+		t.AppendSeparator()
+	}
+}
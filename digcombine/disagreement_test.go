@@ -0,0 +1,91 @@
+package digcombine
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.bbkane.com/shovel/counter"
+	"go.bbkane.com/shovel/dig"
+)
+
+func TestGroupNameservers(t *testing.T) {
+	t.Parallel()
+
+	params := dig.CombineDigRepeatParams(
+		[]string{"198.51.100.1:53", "198.51.100.2:53", "198.51.100.3:53", "198.51.100.4:53"},
+		"udp",
+		[]string{"www.example.com", "api.example.com"},
+		[]uint16{1},
+		[]netip.Prefix{{}},
+		1,
+	)
+	answer := func(answer string) dig.DigRepeatResult {
+		return dig.DigRepeatResult{
+			Answers:         []counter.StringSliceCount{{StringSlice: []string{answer}, Count: 1}},
+			AnswerTTLs:      nil,
+			Errors:          nil,
+			Latency:         dig.LatencyStats{Min: 0, P50: 0, P90: 0, P99: 0, Max: 0},
+			AnswersOverTime: nil,
+		}
+	}
+	results := []dig.DigRepeatResult{
+		// www: one outlier
+		answer("1.1.1.1"), answer("1.1.1.1"), answer("2.2.2.2"), answer("1.1.1.1"),
+		// api: all agree
+		answer("3.3.3.3"), answer("3.3.3.3"), answer("3.3.3.3"), answer("3.3.3.3"),
+	}
+
+	groups := GroupNameservers(params, results)
+	require.Len(t, groups, 2)
+
+	require.Equal(t, "www.example.com", groups[0].Params.DigOneParams.Qname)
+	require.True(t, groups[0].Disagree())
+	require.Equal(t, "1.1.1.1", groups[0].Consensus)
+	require.Equal(t, []string{"198.51.100.1:53", "198.51.100.2:53", "198.51.100.4:53"}, groups[0].ConsensusNameservers)
+	require.Equal(t, []Outlier{{Nameserver: "198.51.100.3:53", Signature: "2.2.2.2"}}, groups[0].Outliers)
+	require.InDelta(t, 75.0, groups[0].AgreementPercent, 0.001)
+
+	require.False(t, groups[1].Disagree())
+	require.InDelta(t, 100.0, groups[1].AgreementPercent, 0.001)
+
+	require.Equal(t, map[string]bool{"www.example.com A": true}, disagreeingGroups(groups))
+}
+
+func TestGroupNameserversIgnoresErrorsWithAnswers(t *testing.T) {
+	t.Parallel()
+
+	params := dig.CombineDigRepeatParams(
+		[]string{"198.51.100.1:53", "198.51.100.2:53"},
+		"udp",
+		[]string{"www.example.com"},
+		[]uint16{1},
+		[]netip.Prefix{{}},
+		3,
+	)
+	answers := []counter.StringSliceCount{{StringSlice: []string{"1.1.1.1"}, Count: 3}}
+	timeout := []dig.ErrorCount{{Category: dig.ErrorTimeout, Count: 1, Samples: nil}}
+	results := []dig.DigRepeatResult{
+		{
+			Answers:         answers,
+			AnswerTTLs:      nil,
+			Errors:          nil,
+			Latency:         dig.LatencyStats{Min: 0, P50: 0, P90: 0, P99: 0, Max: 0},
+			AnswersOverTime: nil,
+		},
+		{
+			Answers:         []counter.StringSliceCount{{StringSlice: []string{"1.1.1.1"}, Count: 2}},
+			AnswerTTLs:      nil,
+			Errors:          timeout,
+			Latency:         dig.LatencyStats{Min: 0, P50: 0, P90: 0, P99: 0, Max: 0},
+			AnswersOverTime: nil,
+		},
+	}
+
+	groups := GroupNameservers(params, results)
+	require.Len(t, groups, 1)
+	require.False(t, groups[0].Disagree())
+	require.Equal(t, "1.1.1.1", groups[0].Consensus)
+	require.Equal(t, []NameserverErrors{{Nameserver: "198.51.100.2:53", Errors: timeout}}, groups[0].Errors)
+	require.Empty(t, disagreeingGroups(groups))
+}
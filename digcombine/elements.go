@@ -0,0 +1,58 @@
+package digcombine
+
+import (
+	"strconv"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/miekg/dns"
+	"go.bbkane.com/shovel/dig"
+)
+
+// printElementStats prints how often each individual answer appeared for every combination
+func printElementStats(t table.Writer, parsed parsedCmdCtx, results []dig.DigRepeatResult, shown func(i int) bool) {
+	//nolint:exhaustruct
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{Name: "Qname", AutoMerge: true},
+		{Name: "Rtype", AutoMerge: true},
+		{Name: "Subnet", AutoMerge: true, Hidden: parsed.DigRepeatParams[0].DigOneParams.SubnetIP == nil},
+		{Name: "Nameserver", AutoMerge: true},
+		{Name: "Answer"},
+		{Name: "Count"},
+		{Name: "Share"},
+		// positions are meaningless when answers are sorted
+		{Name: "Avg Position", Hidden: !parsed.OrderedAnswers},
+	})
+	t.SetTitle("Answers")
+	t.AppendHeader(table.Row{"Qname", "Rtype", "Subnet", "Nameserver", "Answer", "Count", "Share", "Avg Position"})
+
+	for i, p := range parsed.DigRepeatParams {
+		if !shown(i) {
+			continue
+		}
+		qname := p.DigOneParams.Qname
+		if ip, isIP := parsed.QnameToIP[qname]; isIP {
+			qname = ip
+		}
+		subnet := ""
+		if p.DigOneParams.SubnetIP != nil {
+			subnet = dig.SubnetString(p.DigOneParams.SubnetIP, p.DigOneParams.SubnetBits)
+		}
+		ns := "# " + parsed.NameserverNames[p.DigOneParams.NameserverIPPort] + "\n" + p.DigOneParams.NameserverIPPort
+		rtype := dns.TypeToString[p.DigOneParams.Rtype]
+
+		for _, e := range dig.NewElementStats(results[i].Answers) {
+			answer, _ := parsed.AnswerMap.Label(e.Answer)
+			t.AppendRow(table.Row{
+				qname,
+				rtype,
+				subnet,
+				ns,
+				answer,
+				e.Count,
+				strconv.FormatFloat(100*e.Share, 'f', 1, 64) + "%",
+				strconv.FormatFloat(e.AvgPosition, 'f', 2, 64),
+			})
+		}
+		t.AppendSeparator()
+	}
+}
@@ -0,0 +1,78 @@
+package digcombine
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"go.bbkane.com/shovel/dig"
+	"go.bbkane.com/shovel/expect"
+)
+
+// checkExpectations prints mismatches with --expect and optionally writes JUnit XML.
+// It returns an error if any expectation failed so the exit code is non-zero.
+func checkExpectations(parsed parsedCmdCtx, results []dig.DigRepeatResult, elapsed time.Duration) error {
+	if parsed.Expectations == nil {
+		return nil
+	}
+
+	cases := make([]expect.Case, 0, len(parsed.DigRepeatParams))
+	for i, p := range parsed.DigRepeatParams {
+		qname := p.DigOneParams.Qname
+		if ip, isIP := parsed.QnameToIP[qname]; isIP {
+			qname = ip
+		}
+		cases = append(cases, expect.Case{
+			Params:         p,
+			Result:         results[i],
+			Qname:          qname,
+			SubnetName:     parsed.SubnetToName[dig.SubnetString(p.DigOneParams.SubnetIP, p.DigOneParams.SubnetBits)],
+			NameserverName: parsed.NameserverNames[p.DigOneParams.NameserverIPPort],
+		})
+	}
+	outcomes := expect.Evaluate(parsed.Expectations, cases)
+	failed := expect.CountFailed(outcomes)
+
+	if parsed.JUnitPath != "" {
+		f, err := os.Create(parsed.JUnitPath)
+		if err != nil {
+			return fmt.Errorf("could not create junit file: %w", err)
+		}
+		defer f.Close()
+		if err := expect.WriteJUnit(f, outcomes, elapsed); err != nil {
+			return err
+		}
+	}
+
+	if failed == 0 {
+		fmt.Fprintf(parsed.Stdout, "All %d expectation checks passed\n", len(outcomes))
+		return nil
+	}
+
+	t := table.NewWriter()
+	t.SetStyle(table.StyleRounded)
+	t.SetOutputMirror(parsed.Stdout)
+	//nolint:exhaustruct
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{Name: "Expectation", AutoMerge: true},
+		{Name: "Dig"},
+		{Name: "Failures"},
+	})
+	t.AppendHeader(table.Row{"Expectation", "Dig", "Failures"})
+	for _, o := range outcomes {
+		if o.Passed() {
+			continue
+		}
+		digName := ""
+		if o.Case != nil {
+			digName = o.Case.Name()
+		}
+		t.AppendRow(table.Row{o.Expectation.Name(), digName, strings.Join(o.Failures, "\n")})
+		t.AppendSeparator()
+	}
+	t.Render()
+
+	return fmt.Errorf("%d of %d expectation checks failed", failed, len(outcomes))
+}
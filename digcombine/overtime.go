@@ -0,0 +1,215 @@
+package digcombine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/miekg/dns"
+	"go.bbkane.com/shovel/counter"
+	"go.bbkane.com/shovel/dig"
+)
+
+// Sparkline draws shares from 0 to 1 as block characters. Windows without responses are drawn as spaces
+func Sparkline(shares []float64, hasData []bool) string {
+	blocks := []rune("▁▂▃▄▅▆▇█")
+	sb := strings.Builder{}
+	for i, share := range shares {
+		if !hasData[i] {
+			sb.WriteRune(' ')
+			continue
+		}
+		idx := int(math.Round(share * float64(len(blocks)-1)))
+		sb.WriteRune(blocks[max(0, min(idx, len(blocks)-1))])
+	}
+	return sb.String()
+}
+
+// AnswerSeries is the share of responses an answer set got in each window
+type AnswerSeries struct {
+	Answers []string
+	// Shares from 0 to 1, one per window. 0 for windows without responses
+	Shares []float64
+	Counts []int
+}
+
+// NewAnswerSeries lines up each answer set's share of every window. Answer sets are sorted asc
+func NewAnswerSeries(buckets []counter.StringSliceBucket) []AnswerSeries {
+	all := counter.NewStringSliceCounter()
+	for _, b := range buckets {
+		for _, c := range b.Counts {
+			all.Add(c.StringSlice)
+		}
+	}
+
+	ret := []AnswerSeries{}
+	for _, ans := range all.AsSortedSlice() {
+		key := counter.SliceKey(ans.StringSlice)
+		s := AnswerSeries{
+			Answers: ans.StringSlice,
+			Shares:  make([]float64, len(buckets)),
+			Counts:  make([]int, len(buckets)),
+		}
+		for i, b := range buckets {
+			for _, c := range b.Counts {
+				if counter.SliceKey(c.StringSlice) == key {
+					s.Counts[i] = c.Count
+					s.Shares[i] = float64(c.Count) / float64(b.Total())
+				}
+			}
+		}
+		ret = append(ret, s)
+	}
+	return ret
+}
+
+func fmtShare(share float64) string {
+	return fmt.Sprintf("%.0f%%", 100*share)
+}
+
+func printAnswersOverTimeSparklines(t table.Writer, parsed parsedCmdCtx, results []dig.DigRepeatResult) {
+	//nolint:exhaustruct
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{Name: "Combination", AutoMerge: true},
+	})
+	t.SetTitle(fmt.Sprintf("Answers over time (%s windows)", parsed.Interval))
+	t.AppendHeader(table.Row{"Combination", "Ans/Err", "Share", "First", "Last"})
+	for i, p := range parsed.DigRepeatParams {
+		buckets := results[i].AnswersOverTime
+		hasData := make([]bool, len(buckets))
+		firstIdx, lastIdx := -1, -1
+		for j, b := range buckets {
+			hasData[j] = b.Total() > 0
+			if hasData[j] {
+				if firstIdx == -1 {
+					firstIdx = j
+				}
+				lastIdx = j
+			}
+		}
+		for _, s := range NewAnswerSeries(buckets) {
+			t.AppendRow(table.Row{
+				digName(p.DigOneParams, parsed.QnameToIP, true),
+				strings.Join(s.Answers, "\n"),
+				Sparkline(s.Shares, hasData),
+				fmtShare(s.Shares[firstIdx]),
+				fmtShare(s.Shares[lastIdx]),
+			})
+		}
+		t.AppendSeparator()
+	}
+}
+
+func printAnswersOverTimeTable(t table.Writer, parsed parsedCmdCtx, results []dig.DigRepeatResult) {
+	//nolint:exhaustruct
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{Name: "Combination", AutoMerge: true},
+		{Name: "Window", AutoMerge: true},
+	})
+	t.SetTitle(fmt.Sprintf("Answers over time (%s windows)", parsed.Interval))
+	t.AppendHeader(table.Row{"Combination", "Window", "Ans/Err", "Count", "Share"})
+	for i, p := range parsed.DigRepeatParams {
+		for _, b := range results[i].AnswersOverTime {
+			for _, c := range b.Counts {
+				t.AppendRow(table.Row{
+					digName(p.DigOneParams, parsed.QnameToIP, true),
+					"+" + b.Start.String(),
+					strings.Join(c.StringSlice, "\n"),
+					c.Count,
+					fmtShare(float64(c.Count) / float64(b.Total())),
+				})
+			}
+		}
+		t.AppendSeparator()
+	}
+}
+
+// AnswersOverTimeJSON is the --interval-format json output for one combination
+type AnswersOverTimeJSON struct {
+	Qname      string       `json:"qname"`
+	Rtype      string       `json:"rtype"`
+	Subnet     string       `json:"subnet,omitempty"`
+	Nameserver string       `json:"nameserver"`
+	Interval   string       `json:"interval"`
+	Windows    []WindowJSON `json:"windows"`
+}
+
+// WindowJSON counts the answers of one window
+type WindowJSON struct {
+	// Start of the window relative to the start of the run. Example: 10s
+	Start   string            `json:"start"`
+	Total   int               `json:"total"`
+	Answers []AnswerCountJSON `json:"answers"`
+}
+
+// AnswerCountJSON is an answer set's count and share of a window
+type AnswerCountJSON struct {
+	Answers []string `json:"answers"`
+	Count   int      `json:"count"`
+	Percent float64  `json:"percent"`
+}
+
+func answersOverTimeJSON(parsed parsedCmdCtx, results []dig.DigRepeatResult) []AnswersOverTimeJSON {
+	ret := make([]AnswersOverTimeJSON, 0, len(parsed.DigRepeatParams))
+	for i, p := range parsed.DigRepeatParams {
+		qname := p.DigOneParams.Qname
+		if ip, isIP := parsed.QnameToIP[qname]; isIP {
+			qname = ip
+		}
+		subnet := ""
+		if p.DigOneParams.SubnetIP != nil {
+			subnet = dig.SubnetString(p.DigOneParams.SubnetIP, p.DigOneParams.SubnetBits)
+		}
+		windows := []WindowJSON{}
+		for _, b := range results[i].AnswersOverTime {
+			answers := []AnswerCountJSON{}
+			total := b.Total()
+			for _, c := range b.Counts {
+				answers = append(answers, AnswerCountJSON{
+					Answers: c.StringSlice,
+					Count:   c.Count,
+					Percent: 100 * float64(c.Count) / float64(total),
+				})
+			}
+			windows = append(windows, WindowJSON{
+				Start:   b.Start.String(),
+				Total:   total,
+				Answers: answers,
+			})
+		}
+		ret = append(ret, AnswersOverTimeJSON{
+			Qname:      qname,
+			Rtype:      dns.TypeToString[p.DigOneParams.Rtype],
+			Subnet:     subnet,
+			Nameserver: p.DigOneParams.NameserverIPPort,
+			Interval:   parsed.Interval.String(),
+			Windows:    windows,
+		})
+	}
+	return ret
+}
+
+// printAnswersOverTime prints how the answers of each combination changed over the run in --interval-format
+func printAnswersOverTime(parsed parsedCmdCtx, results []dig.DigRepeatResult) error {
+	if parsed.IntervalFormat == "json" {
+		encoder := json.NewEncoder(parsed.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(answersOverTimeJSON(parsed, results)); err != nil {
+			return fmt.Errorf("could not write answers over time: %w", err)
+		}
+		return nil
+	}
+
+	t := table.NewWriter()
+	t.SetStyle(table.StyleRounded)
+	t.SetOutputMirror(parsed.Stdout)
+	if parsed.IntervalFormat == "table" {
+		printAnswersOverTimeTable(t, parsed, results)
+	} else {
+		printAnswersOverTimeSparklines(t, parsed, results)
+	}
+	t.Render()
+	return nil
+}
@@ -0,0 +1,35 @@
+package digcombine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.bbkane.com/shovel/counter"
+)
+
+func TestSparkline(t *testing.T) {
+	t.Parallel()
+
+	actual := Sparkline([]float64{0, 0.5, 1, 0}, []bool{true, true, true, false})
+	require.Equal(t, "▁▅█ ", actual)
+}
+
+func TestNewAnswerSeries(t *testing.T) {
+	t.Parallel()
+
+	buckets := []counter.StringSliceBucket{
+		{Start: 0, Counts: []counter.StringSliceCount{{StringSlice: []string{"1.1.1.1"}, Count: 4}}},
+		{Start: 10 * time.Second, Counts: nil},
+		{Start: 20 * time.Second, Counts: []counter.StringSliceCount{
+			{StringSlice: []string{"1.1.1.1"}, Count: 1},
+			{StringSlice: []string{"2.2.2.2"}, Count: 3},
+		}},
+	}
+
+	expected := []AnswerSeries{
+		{Answers: []string{"1.1.1.1"}, Shares: []float64{1, 0, 0.25}, Counts: []int{4, 0, 1}},
+		{Answers: []string{"2.2.2.2"}, Shares: []float64{0, 0, 0.75}, Counts: []int{0, 0, 3}},
+	}
+	require.Equal(t, expected, NewAnswerSeries(buckets))
+}
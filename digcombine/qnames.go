@@ -0,0 +1,304 @@
+package digcombine
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// maxBraceExpansion limits how many names a single qname can expand to so a typo like {1..100000000} fails fast
+const maxBraceExpansion = 100_000
+
+// findBraceExpr returns the indexes of the first top-level {...} expression in s at or after start,
+// skipping {{placeholder}} templates. found is false if there isn't one.
+func findBraceExpr(s string, start int) (int, int, bool) {
+	for i := start; i < len(s); i++ {
+		if strings.HasPrefix(s[i:], "{{") {
+			end := strings.Index(s[i:], "}}")
+			if end == -1 {
+				return 0, 0, false
+			}
+			i += end + 1
+			continue
+		}
+		if s[i] != '{' {
+			continue
+		}
+		depth := 0
+		for j := i; j < len(s); j++ {
+			switch s[j] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					return i, j, true
+				}
+			}
+		}
+		return 0, 0, false
+	}
+	return 0, 0, false
+}
+
+// splitTopLevelCommas splits s on commas not nested in braces
+func splitTopLevelCommas(s string) []string {
+	parts := []string{}
+	depth := 0
+	last := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(parts, s[last:])
+}
+
+// braceRangeRe matches the inside of a numeric range like {1..10..2}
+var braceRangeRe = regexp.MustCompile(`^(-?\d+)\.\.(-?\d+)(?:\.\.(-?\d+))?$`)
+
+// expandRange expands bash style numeric ranges like 1..10, 01..10 (zero padded) and 1..10..2.
+// Like bash, the sign of the step is ignored. ok is false if expr isn't a range.
+func expandRange(expr string) ([]string, bool, error) {
+	match := braceRangeRe.FindStringSubmatch(expr)
+	if match == nil {
+		return nil, false, nil
+	}
+	first, err := strconv.Atoi(match[1])
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid range start: {%s}: %w", expr, err)
+	}
+	last, err := strconv.Atoi(match[2])
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid range end: {%s}: %w", expr, err)
+	}
+	step := 1
+	if match[3] != "" {
+		step, err = strconv.Atoi(match[3])
+		if err != nil {
+			return nil, true, fmt.Errorf("invalid range step: {%s}: %w", expr, err)
+		}
+		if step == 0 {
+			return nil, true, fmt.Errorf("range step must not be 0: {%s}", expr)
+		}
+	}
+	// count in uint64 so ranges spanning most of int64 don't overflow
+	absStep := uint64(step)
+	if step < 0 {
+		absStep = -uint64(step)
+	}
+	span := uint64(last) - uint64(first)
+	step = int(absStep)
+	if first > last {
+		span = uint64(first) - uint64(last)
+		step = -step
+	}
+
+	width := 0
+	for _, bound := range match[1:3] {
+		digits := strings.TrimPrefix(bound, "-")
+		if len(digits) > 1 && digits[0] == '0' {
+			width = max(width, len(bound))
+		}
+	}
+
+	if span/absStep >= maxBraceExpansion {
+		return nil, true, fmt.Errorf("range expands to more than the limit of %d names: {%s}", maxBraceExpansion, expr)
+	}
+	count := int(span/absStep) + 1
+	ret := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		ret = append(ret, fmt.Sprintf("%0*d", width, first+i*step))
+	}
+	return ret, true, nil
+}
+
+func expandBracesFrom(s string, start int) ([]string, error) {
+	open, closing, found := findBraceExpr(s, start)
+	if !found {
+		return []string{s}, nil
+	}
+	prefix, expr, suffix := s[:open], s[open+1:closing], s[closing+1:]
+
+	alternatives := splitTopLevelCommas(expr)
+	if len(alternatives) == 1 {
+		rangeAlternatives, isRange, err := expandRange(expr)
+		if err != nil {
+			return nil, err
+		}
+		if !isRange {
+			// like bash, leave a brace without commas or a range alone
+			return expandBracesFrom(s, closing+1)
+		}
+		alternatives = rangeAlternatives
+	}
+
+	ret := []string{}
+	for _, alternative := range alternatives {
+		expanded, err := expandBracesFrom(prefix+alternative+suffix, len(prefix))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, expanded...)
+		if len(ret) > maxBraceExpansion {
+			return nil, fmt.Errorf("qname expands to more than the limit of %d names: %s", maxBraceExpansion, s)
+		}
+	}
+	return ret, nil
+}
+
+// ExpandBraces performs bash style brace expansion on a qname.
+// Lists like {api,cdn}.example.com and ranges like web{1..40}.example.com or web{01..40}.example.com are supported, including nesting.
+// {{placeholder}} templates are left alone.
+func ExpandBraces(qname string) ([]string, error) {
+	return expandBracesFrom(qname, 0)
+}
+
+// readQnameLines reads newline separated qnames, skipping blank lines and # comments
+func readQnameLines(r io.Reader) ([]string, error) {
+	qnames := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		qnames = append(qnames, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return qnames, nil
+}
+
+// ExpandQnames expands each passed qname with ExpandBraces.
+// "@path" reads newline separated qnames from a file with readFile and "-" reads them from stdin.
+// Names read this way are brace expanded too.
+// Pass a nil readFile or stdin to disallow reading from that source.
+func ExpandQnames(passed []string, readFile func(name string) ([]byte, error), stdin io.Reader) ([]string, error) {
+	unexpanded := []string{}
+	for _, p := range passed {
+		switch {
+		case p == "-":
+			if stdin == nil {
+				return nil, errors.New("reading qnames from stdin is not supported here")
+			}
+			lines, err := readQnameLines(stdin)
+			if err != nil {
+				return nil, fmt.Errorf("could not read qnames from stdin: %w", err)
+			}
+			unexpanded = append(unexpanded, lines...)
+		case strings.HasPrefix(p, "@"):
+			if readFile == nil {
+				return nil, errors.New("reading qnames from files is not supported here: " + p)
+			}
+			contents, err := readFile(strings.TrimPrefix(p, "@"))
+			if err != nil {
+				return nil, fmt.Errorf("could not read qnames file: %w", err)
+			}
+			lines, err := readQnameLines(bytes.NewReader(contents))
+			if err != nil {
+				return nil, fmt.Errorf("could not read qnames file: %s: %w", p, err)
+			}
+			unexpanded = append(unexpanded, lines...)
+		default:
+			unexpanded = append(unexpanded, p)
+		}
+	}
+
+	qnames := []string{}
+	for _, u := range unexpanded {
+		expanded, err := ExpandBraces(u)
+		if err != nil {
+			return nil, err
+		}
+		qnames = append(qnames, expanded...)
+	}
+	return qnames, nil
+}
+
+// ReverseQnames replaces qnames that are IP addresses or CIDR ranges with the in-addr.arpa/ip6.arpa names
+// for PTR lookups. CIDR ranges produce one name per address.
+// It returns an error if there are IP qnames and an rtype other than PTR, as every qname is dug with every rtype.
+// It also returns a map of each generated name to the IP it was generated from.
+func ReverseQnames(qnames []string, rtypes []uint16) ([]string, map[string]string, error) {
+	ret := []string{}
+	arpaToIP := make(map[string]string)
+
+	addAddr := func(addr netip.Addr) error {
+		arpa, err := dns.ReverseAddr(addr.String())
+		if err != nil {
+			return fmt.Errorf("could not reverse IP qname for PTR lookups: %s: %w", addr, err)
+		}
+		ret = append(ret, arpa)
+		arpaToIP[arpa] = addr.String()
+		return nil
+	}
+
+	checkRtypes := func(qname string) error {
+		for _, rtype := range rtypes {
+			if rtype != dns.TypePTR {
+				return fmt.Errorf("IP address and CIDR qnames can only be dug with rtype PTR, not %s: %s", dns.TypeToString[rtype], qname)
+			}
+		}
+		return nil
+	}
+
+	for _, qname := range qnames {
+		if addr, err := netip.ParseAddr(qname); err == nil {
+			if err := checkRtypes(qname); err != nil {
+				return nil, nil, err
+			}
+			if err := addAddr(addr); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		prefix, err := netip.ParsePrefix(qname)
+		if err != nil {
+			ret = append(ret, qname)
+			continue
+		}
+		if err := checkRtypes(qname); err != nil {
+			return nil, nil, err
+		}
+		prefix = prefix.Masked()
+		if prefix.Addr().BitLen()-prefix.Bits() > 16 {
+			return nil, nil, fmt.Errorf("CIDR range too large for reverse lookups (at most 65536 addresses): %s", qname)
+		}
+		for addr := prefix.Addr(); addr.IsValid() && prefix.Contains(addr); addr = addr.Next() {
+			if err := addAddr(addr); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return ret, arpaToIP, nil
+}
+
+// CheckCombinationCount returns an error reporting the total number of combinations if it exceeds maxCombinations
+func CheckCombinationCount(qnames int, rtypes int, subnets int, nameservers int, maxCombinations int) error {
+	total := qnames * rtypes * subnets * nameservers
+	if total > maxCombinations {
+		return fmt.Errorf(
+			"%d combinations (%d qnames x %d rtypes x %d subnets x %d nameservers) exceeds the maximum of %d",
+			total, qnames, rtypes, subnets, nameservers, maxCombinations,
+		)
+	}
+	return nil
+}
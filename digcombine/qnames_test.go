@@ -0,0 +1,283 @@
+package digcombine
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandBraces(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		qname       string
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			name:        "noBraces",
+			qname:       "www.example.com",
+			expected:    []string{"www.example.com"},
+			expectedErr: false,
+		},
+		{
+			name:        "list",
+			qname:       "{api,cdn}.example.com",
+			expected:    []string{"api.example.com", "cdn.example.com"},
+			expectedErr: false,
+		},
+		{
+			name:        "range",
+			qname:       "web{1..3}.example.com",
+			expected:    []string{"web1.example.com", "web2.example.com", "web3.example.com"},
+			expectedErr: false,
+		},
+		{
+			name:        "zeroPaddedReverseRange",
+			qname:       "web{10..08}",
+			expected:    []string{"web10", "web09", "web08"},
+			expectedErr: false,
+		},
+		{
+			name:        "stepAndProduct",
+			qname:       "{a,b}{1..5..2}",
+			expected:    []string{"a1", "a3", "a5", "b1", "b3", "b5"},
+			expectedErr: false,
+		},
+		{
+			name:        "nested",
+			qname:       "{www,{api,cdn}-{1..2}}.example.com",
+			expected:    []string{"www.example.com", "api-1.example.com", "api-2.example.com", "cdn-1.example.com", "cdn-2.example.com"},
+			expectedErr: false,
+		},
+		{
+			name:        "templateUntouched",
+			qname:       "{{rand}}.{a,b}.example.com",
+			expected:    []string{"{{rand}}.a.example.com", "{{rand}}.b.example.com"},
+			expectedErr: false,
+		},
+		{
+			name:        "literalBrace",
+			qname:       "{a}.example.com",
+			expected:    []string{"{a}.example.com"},
+			expectedErr: false,
+		},
+		{
+			name:        "tooBig",
+			qname:       "{1..1000000}.example.com",
+			expected:    nil,
+			expectedErr: true,
+		},
+		{
+			name:        "overflow",
+			qname:       "{1..99999999999999999999}.example.com",
+			expected:    nil,
+			expectedErr: true,
+		},
+		{
+			name:        "extremeBounds",
+			qname:       "{-9000000000000000000..9000000000000000000}.example.com",
+			expected:    nil,
+			expectedErr: true,
+		},
+		{
+			name:        "extremeBoundsReverse",
+			qname:       "{9223372036854775807..-9223372036854775808}.example.com",
+			expected:    nil,
+			expectedErr: true,
+		},
+		{
+			name:        "extremeBoundsHugeStep",
+			qname:       "{-9223372036854775808..9223372036854775807..9223372036854775807}",
+			expected:    []string{"-9223372036854775808", "-1", "9223372036854775806"},
+			expectedErr: false,
+		},
+		{
+			name:        "negativeStep",
+			qname:       "{1..5..-2}",
+			expected:    []string{"1", "3", "5"},
+			expectedErr: false,
+		},
+		{
+			name:        "minStep",
+			qname:       "{1..5..-9223372036854775808}",
+			expected:    []string{"1"},
+			expectedErr: false,
+		},
+		{
+			name:        "zeroStep",
+			qname:       "{1..5..0}",
+			expected:    nil,
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ExpandBraces(tt.qname)
+			if tt.expectedErr {
+				require.NotNil(t, err)
+			} else {
+				require.Nil(t, err)
+			}
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestExpandQnames(t *testing.T) {
+	t.Parallel()
+
+	readFile := func(name string) ([]byte, error) {
+		if name == "hosts.txt" {
+			return []byte("# fleet\nweb{1..2}.example.com\n\ndb.example.com\n"), nil
+		}
+		return nil, errors.New("file not found")
+	}
+
+	tests := []struct {
+		name        string
+		passed      []string
+		readFile    func(name string) ([]byte, error)
+		stdin       string
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			name:        "file",
+			passed:      []string{"example.com", "@hosts.txt"},
+			readFile:    readFile,
+			stdin:       "",
+			expected:    []string{"example.com", "web1.example.com", "web2.example.com", "db.example.com"},
+			expectedErr: false,
+		},
+		{
+			name:        "stdin",
+			passed:      []string{"-"},
+			readFile:    nil,
+			stdin:       "a.example.com\n  b.example.com  \n",
+			expected:    []string{"a.example.com", "b.example.com"},
+			expectedErr: false,
+		},
+		{
+			name:        "missingFile",
+			passed:      []string{"@nothere.txt"},
+			readFile:    readFile,
+			stdin:       "",
+			expected:    nil,
+			expectedErr: true,
+		},
+		{
+			name:        "filesDisallowed",
+			passed:      []string{"@hosts.txt"},
+			readFile:    nil,
+			stdin:       "",
+			expected:    nil,
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ExpandQnames(tt.passed, tt.readFile, strings.NewReader(tt.stdin))
+			if tt.expectedErr {
+				require.NotNil(t, err)
+			} else {
+				require.Nil(t, err)
+			}
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestCheckCombinationCount(t *testing.T) {
+	t.Parallel()
+	require.Nil(t, CheckCombinationCount(10, 2, 1, 5, 100))
+	require.EqualError(
+		t,
+		CheckCombinationCount(10, 2, 1, 6, 100),
+		"120 combinations (10 qnames x 2 rtypes x 1 subnets x 6 nameservers) exceeds the maximum of 100",
+	)
+}
+
+func TestReverseQnames(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		qnames           []string
+		rtypes           []uint16
+		expectedQnames   []string
+		expectedArpaToIP map[string]string
+		expectedErr      bool
+	}{
+		{
+			name:             "notIP",
+			qnames:           []string{"example.com"},
+			rtypes:           []uint16{dns.TypeA},
+			expectedQnames:   []string{"example.com"},
+			expectedArpaToIP: map[string]string{},
+			expectedErr:      false,
+		},
+		{
+			name:           "ipv4AndIPv6",
+			qnames:         []string{"192.0.2.1", "2001:db8::1"},
+			rtypes:         []uint16{dns.TypePTR},
+			expectedQnames: []string{"1.2.0.192.in-addr.arpa.", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."},
+			expectedArpaToIP: map[string]string{
+				"1.2.0.192.in-addr.arpa.": "192.0.2.1",
+				"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.": "2001:db8::1",
+			},
+			expectedErr: false,
+		},
+		{
+			name:           "cidr",
+			qnames:         []string{"192.0.2.5/31"},
+			rtypes:         []uint16{dns.TypePTR},
+			expectedQnames: []string{"4.2.0.192.in-addr.arpa.", "5.2.0.192.in-addr.arpa."},
+			expectedArpaToIP: map[string]string{
+				"4.2.0.192.in-addr.arpa.": "192.0.2.4",
+				"5.2.0.192.in-addr.arpa.": "192.0.2.5",
+			},
+			expectedErr: false,
+		},
+		{
+			name:             "cidrTooLarge",
+			qnames:           []string{"10.0.0.0/8"},
+			rtypes:           []uint16{dns.TypePTR},
+			expectedQnames:   nil,
+			expectedArpaToIP: nil,
+			expectedErr:      true,
+		},
+		{
+			name:             "zonedIPv6",
+			qnames:           []string{"fe80::1%eth0"},
+			rtypes:           []uint16{dns.TypePTR},
+			expectedQnames:   nil,
+			expectedArpaToIP: nil,
+			expectedErr:      true,
+		},
+		{
+			name:             "notPTR",
+			qnames:           []string{"example.com", "192.0.2.1"},
+			rtypes:           []uint16{dns.TypePTR, dns.TypeA},
+			expectedQnames:   nil,
+			expectedArpaToIP: nil,
+			expectedErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actualQnames, actualArpaToIP, err := ReverseQnames(tt.qnames, tt.rtypes)
+			if tt.expectedErr {
+				require.NotNil(t, err)
+			} else {
+				require.Nil(t, err)
+			}
+			require.Equal(t, tt.expectedQnames, actualQnames)
+			require.Equal(t, tt.expectedArpaToIP, actualArpaToIP)
+		})
+	}
+}
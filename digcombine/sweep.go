@@ -0,0 +1,200 @@
+package digcombine
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/miekg/dns"
+	"go.bbkane.com/shovel/dig"
+)
+
+// maxSweepBlocks limits how many blocks a single CIDR can be swept into
+const maxSweepBlocks = 1 << 16
+
+// lastAddr returns the last address in a prefix
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Masked().Addr().As16()
+	hostBits := p.Addr().BitLen() - p.Bits()
+	for i := 15; hostBits > 0; i-- {
+		n := min(hostBits, 8)
+		b[i] |= byte(1<<n - 1)
+		hostBits -= n
+	}
+	addr := netip.AddrFrom16(b)
+	if p.Addr().Is4() {
+		addr = addr.Unmap()
+	}
+	return addr
+}
+
+// SweepSubnets splits each subnet with a prefix length shorter than step into /step blocks,
+// so an ECS query can be sent for each block.
+// Swept blocks are named after their parent in subnetToName.
+// It returns the blocks and a map of each block to the subnet it was swept from.
+// A step of 0 disables sweeping.
+func SweepSubnets(subnets []netip.Prefix, subnetToName map[string]string, step int) ([]netip.Prefix, map[netip.Prefix]netip.Prefix, error) {
+	parents := make(map[netip.Prefix]netip.Prefix)
+	if step == 0 {
+		return subnets, parents, nil
+	}
+
+	swept := []netip.Prefix{}
+	for _, subnet := range subnets {
+		if !subnet.IsValid() || subnet.Bits() >= step {
+			swept = append(swept, subnet)
+			continue
+		}
+		if step > subnet.Addr().BitLen() {
+			return nil, nil, fmt.Errorf("subnet step /%d is too long for %s", step, subnet)
+		}
+		if step-subnet.Bits() > 16 {
+			return nil, nil, fmt.Errorf("sweeping %s in /%d steps would need more than %d blocks", subnet, step, maxSweepBlocks)
+		}
+
+		parentName := subnetToName[dig.SubnetString(net.IP(subnet.Addr().AsSlice()), subnet.Bits())]
+		addr := subnet.Addr()
+		for i := 0; i < 1<<(step-subnet.Bits()); i++ {
+			block := netip.PrefixFrom(addr, step)
+			swept = append(swept, block)
+			parents[block] = subnet
+			if subnetToName != nil {
+				subnetToName[dig.SubnetString(net.IP(addr.AsSlice()), step)] = parentName
+			}
+			addr = lastAddr(block).Next()
+		}
+	}
+	return swept, parents, nil
+}
+
+// resultSignature summarizes the distinct answers and errors in a result, ignoring counts
+func resultSignature(r dig.DigRepeatResult) string {
+	lines := []string{}
+	for _, ans := range r.Answers {
+		lines = append(lines, strings.Join(ans.StringSlice, "\n"))
+	}
+	for _, err := range r.Errors {
+		// sample messages can differ between otherwise identical results
+		lines = append(lines, string(err.Category))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SweepRange is a run of adjacent swept blocks that got the same answers
+type SweepRange struct {
+	Qname      string
+	Rtype      uint16
+	Nameserver string
+	// First and Last blocks in the range
+	First netip.Prefix
+	Last  netip.Prefix
+	// Blocks in the range
+	Blocks int
+	// Result of the first block in the range. The distinct answers and errors of the rest of the blocks match it
+	Result dig.DigRepeatResult
+}
+
+// String formats the range of addresses covered
+func (r SweepRange) String() string {
+	if !r.First.IsValid() {
+		return ""
+	}
+	if r.First == r.Last {
+		return r.First.String()
+	}
+	return fmt.Sprintf("%s - %s (%d x /%d)", r.First.Addr(), lastAddr(r.Last), r.Blocks, r.First.Bits())
+}
+
+// CollapseSweep merges adjacent blocks from the same swept subnet that got identical answers
+// (ignoring counts) for each qname/rtype/nameserver.
+// params and results must be in the order returned by dig.CombineDigRepeatParams and parents comes from SweepSubnets.
+func CollapseSweep(params []dig.DigRepeatParams, results []dig.DigRepeatResult, parents map[netip.Prefix]netip.Prefix) []SweepRange {
+	type groupKey struct {
+		qname      string
+		rtype      uint16
+		nameserver string
+		parent     netip.Prefix
+	}
+	groupOrder := []groupKey{}
+	groups := make(map[groupKey][]SweepRange)
+
+	for i, p := range params {
+		block := netip.Prefix{}
+		if p.DigOneParams.SubnetIP != nil {
+			addr, _ := netip.AddrFromSlice(p.DigOneParams.SubnetIP)
+			bits := p.DigOneParams.SubnetBits
+			if bits == dig.FullSubnetBits {
+				bits = addr.Unmap().BitLen()
+			}
+			block = netip.PrefixFrom(addr.Unmap(), bits)
+		}
+		parent, swept := parents[block]
+		if !swept {
+			parent = block
+		}
+		key := groupKey{
+			qname:      p.DigOneParams.Qname,
+			rtype:      p.DigOneParams.Rtype,
+			nameserver: p.DigOneParams.NameserverIPPort,
+			parent:     parent,
+		}
+		ranges, exists := groups[key]
+		if !exists {
+			groupOrder = append(groupOrder, key)
+		}
+
+		if swept && len(ranges) > 0 {
+			prev := &ranges[len(ranges)-1]
+			if lastAddr(prev.Last).Next() == block.Addr() && resultSignature(prev.Result) == resultSignature(results[i]) {
+				prev.Last = block
+				prev.Blocks++
+				continue
+			}
+		}
+		groups[key] = append(ranges, SweepRange{
+			Qname:      key.qname,
+			Rtype:      key.rtype,
+			Nameserver: key.nameserver,
+			First:      block,
+			Last:       block,
+			Blocks:     1,
+			Result:     results[i],
+		})
+	}
+
+	ret := []SweepRange{}
+	for _, key := range groupOrder {
+		ret = append(ret, groups[key]...)
+	}
+	return ret
+}
+
+// printSweepRanges renders collapsed sweep ranges as a table
+func printSweepRanges(t table.Writer, parsed parsedCmdCtx, ranges []SweepRange) {
+	//nolint:exhaustruct
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{Name: "Qname", AutoMerge: true},
+		{Name: "Rtype", AutoMerge: true},
+		{Name: "Nameserver", AutoMerge: true},
+		{Name: "Subnet Range"},
+		{Name: "Ans/Err"},
+	})
+	t.AppendHeader(table.Row{"Qname", "Rtype", "Nameserver", "Subnet Range", "Ans/Err"})
+
+	for _, r := range ranges {
+		qname := r.Qname
+		if ip, isIP := parsed.QnameToIP[qname]; isIP {
+			qname = ip
+		}
+		t.AppendRow(table.Row{
+			qname,
+			dns.TypeToString[r.Rtype],
+			"# " + parsed.NameserverNames[r.Nameserver] + "\n" + r.Nameserver,
+			r.String(),
+			resultSignature(r.Result),
+		})
+		t.AppendSeparator()
+	}
+}
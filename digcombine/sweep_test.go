@@ -0,0 +1,116 @@
+package digcombine
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+	"go.bbkane.com/shovel/counter"
+	"go.bbkane.com/shovel/dig"
+)
+
+func TestSweepSubnets(t *testing.T) {
+	t.Parallel()
+
+	subnetToName := map[string]string{"10.0.0.0/14": "passed cidr", "192.0.2.1": "passed ip"}
+	swept, parents, err := SweepSubnets(
+		[]netip.Prefix{netip.MustParsePrefix("10.0.0.0/14"), netip.MustParsePrefix("192.0.2.1/32")},
+		subnetToName,
+		16,
+	)
+	require.Nil(t, err)
+
+	parent := netip.MustParsePrefix("10.0.0.0/14")
+	require.Equal(
+		t,
+		[]netip.Prefix{
+			netip.MustParsePrefix("10.0.0.0/16"),
+			netip.MustParsePrefix("10.1.0.0/16"),
+			netip.MustParsePrefix("10.2.0.0/16"),
+			netip.MustParsePrefix("10.3.0.0/16"),
+			netip.MustParsePrefix("192.0.2.1/32"),
+		},
+		swept,
+	)
+	require.Equal(
+		t,
+		map[netip.Prefix]netip.Prefix{
+			netip.MustParsePrefix("10.0.0.0/16"): parent,
+			netip.MustParsePrefix("10.1.0.0/16"): parent,
+			netip.MustParsePrefix("10.2.0.0/16"): parent,
+			netip.MustParsePrefix("10.3.0.0/16"): parent,
+		},
+		parents,
+	)
+	require.Equal(t, "passed cidr", subnetToName["10.3.0.0/16"])
+
+	_, _, err = SweepSubnets([]netip.Prefix{netip.MustParsePrefix("0.0.0.0/0")}, nil, 24)
+	require.NotNil(t, err)
+}
+
+func TestCollapseSweep(t *testing.T) {
+	t.Parallel()
+
+	blocks, parents, err := SweepSubnets([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/14")}, nil, 16)
+	require.Nil(t, err)
+
+	params := dig.CombineDigRepeatParams([]string{"1.1.1.1:53"}, "udp", []string{"example.com"}, []uint16{dns.TypeA}, blocks, 2)
+
+	result := func(answer string, count int) dig.DigRepeatResult {
+		return dig.DigRepeatResult{
+			Answers:         []counter.StringSliceCount{{StringSlice: []string{answer}, Count: count}},
+			AnswerTTLs:      []dig.TTLStats{{Min: 0, Max: 0, Decreased: false}},
+			Errors:          nil,
+			Latency:         dig.LatencyStats{Min: 0, P50: 0, P90: 0, P99: 0, Max: 0},
+			AnswersOverTime: nil,
+		}
+	}
+	results := []dig.DigRepeatResult{
+		result("192.0.2.1", 2),
+		result("192.0.2.1", 1), // counts are ignored
+		result("192.0.2.2", 2),
+		result("192.0.2.1", 2),
+	}
+
+	ranges := CollapseSweep(params, results, parents)
+	require.Len(t, ranges, 3)
+	require.Equal(t, "10.0.0.0 - 10.1.255.255 (2 x /16)", ranges[0].String())
+	require.Equal(t, "10.2.0.0/16", ranges[1].String())
+	require.Equal(t, "10.3.0.0/16", ranges[2].String())
+	require.Equal(t, 2, ranges[0].Blocks)
+}
+
+func TestPrintSweepRangesReverse(t *testing.T) {
+	t.Parallel()
+
+	arpa := "1.2.0.192.in-addr.arpa."
+	block := netip.MustParsePrefix("10.0.0.0/16")
+	ranges := []SweepRange{{
+		Qname:      arpa,
+		Rtype:      dns.TypePTR,
+		Nameserver: "198.51.100.1:53",
+		First:      block,
+		Last:       block,
+		Blocks:     1,
+		Result: dig.DigRepeatResult{
+			Answers:         []counter.StringSliceCount{{StringSlice: []string{"host.example.com."}, Count: 1}},
+			AnswerTTLs:      nil,
+			Errors:          nil,
+			Latency:         dig.LatencyStats{Min: 0, P50: 0, P90: 0, P99: 0, Max: 0},
+			AnswersOverTime: nil,
+		},
+	}}
+	//nolint:exhaustruct
+	parsed := parsedCmdCtx{
+		NameserverNames: map[string]string{"198.51.100.1:53": "ns1"},
+		QnameToIP:       map[string]string{arpa: "192.0.2.1"},
+	}
+
+	tw := table.NewWriter()
+	printSweepRanges(tw, parsed, ranges)
+	out := tw.Render()
+	require.Contains(t, out, "192.0.2.1")
+	require.NotContains(t, out, "in-addr.arpa")
+}
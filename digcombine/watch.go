@@ -0,0 +1,242 @@
+package digcombine
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/miekg/dns"
+	"go.bbkane.com/shovel/counter"
+	"go.bbkane.com/shovel/dig"
+)
+
+// clearScreen moves the cursor home and clears the terminal so each round redraws in place
+const clearScreen = "\033[H\033[2J"
+
+// shareStep is the granularity, in percentage points, that answer shares are rounded to when looking for changes,
+// so a weighted shift like 70/30 -> 30/70 is an event but sampling noise mostly isn't
+const shareStep = 10
+
+// TimelineEvent is a change seen between watch rounds
+type TimelineEvent struct {
+	Time time.Time
+	// Dig is the combination (or qname/rtype/subnet group of nameservers) that changed
+	Dig    string
+	Change string
+}
+
+// Timeline records when each combination's answer distribution changed, and when nameservers started and stopped disagreeing
+type Timeline struct {
+	// Events are the latest changes, oldest first
+	Events []TimelineEvent
+	// Elided counts the events dropped to keep at most maxEvents
+	Elided int
+	// maxEvents is how many of the latest events to keep. 0 keeps them all
+	maxEvents int
+	// last distribution signature of each combination. nil before the first round
+	last []string
+	// disagreeSince holds when each group of combinations that differ only by nameserver started disagreeing
+	disagreeSince map[string]time.Time
+}
+
+// NewTimeline keeps the latest maxEvents events, or all of them if maxEvents is 0
+func NewTimeline(maxEvents int) Timeline {
+	return Timeline{
+		Events:        []TimelineEvent{},
+		Elided:        0,
+		maxEvents:     maxEvents,
+		last:          nil,
+		disagreeSince: make(map[string]time.Time),
+	}
+}
+
+// digName describes a combination in one line. Example: www.example.com A 101.251.8.0 8.8.8.8:53
+// Without the nameserver, it names the group of combinations that only differ by nameserver
+func digName(p dig.DigOneParams, qnameToIP map[string]string, withNameserver bool) string {
+	qname := p.Qname
+	if ip, isIP := qnameToIP[qname]; isIP {
+		qname = ip
+	}
+	parts := []string{qname, dns.TypeToString[p.Rtype]}
+	if p.SubnetIP != nil {
+		parts = append(parts, dig.SubnetString(p.SubnetIP, p.SubnetBits))
+	}
+	if withNameserver {
+		parts = append(parts, p.NameserverIPPort)
+	}
+	return strings.Join(parts, " ")
+}
+
+// distributionSignature is like resultSignature, but also includes each answer set or error's share of the digs,
+// rounded to shareStep. Shares are left out when there's only one answer set or error
+func distributionSignature(r dig.DigRepeatResult) string {
+	total := 0
+	for _, ans := range r.Answers {
+		total += ans.Count
+	}
+	for _, err := range r.Errors {
+		total += err.Count
+	}
+	if len(r.Answers)+len(r.Errors) <= 1 {
+		return resultSignature(r)
+	}
+	fmtShare := func(count int) string {
+		share := shareStep * math.Round(100*float64(count)/float64(total)/shareStep)
+		return fmt.Sprintf(" (%.0f%%)", share)
+	}
+
+	lines := []string{}
+	for _, ans := range r.Answers {
+		lines = append(lines, strings.Join(ans.StringSlice, "\n")+fmtShare(ans.Count))
+	}
+	for _, err := range r.Errors {
+		lines = append(lines, string(err.Category)+fmtShare(err.Count))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func fmtSignature(sig string) string {
+	if sig == "" {
+		return "(nothing)"
+	}
+	return strings.ReplaceAll(sig, "\n", ", ")
+}
+
+// Record a round of results. params and results must be in the same order every round
+func (tl *Timeline) Record(now time.Time, params []dig.DigRepeatParams, results []dig.DigRepeatResult, qnameToIP map[string]string) {
+	distributions := make([]string, len(results))
+	sigs := make([]string, len(results))
+	for i, r := range results {
+		distributions[i] = distributionSignature(r)
+		sigs[i] = answerSignature(r)
+	}
+
+	if tl.last != nil {
+		for i := range distributions {
+			if distributions[i] != tl.last[i] {
+				tl.Events = append(tl.Events, TimelineEvent{
+					Time:   now,
+					Dig:    digName(params[i].DigOneParams, qnameToIP, true),
+					Change: fmtSignature(tl.last[i]) + " -> " + fmtSignature(distributions[i]),
+				})
+			}
+		}
+	}
+	tl.last = distributions
+
+	// group by everything but the nameserver, keeping the order groups first appear in.
+	// Nameservers disagree when they return different answers, not just different shares of the same answers
+	groupOrder := []string{}
+	groupSigs := make(map[string]map[string]bool)
+	for i, p := range params {
+		group := digName(p.DigOneParams, qnameToIP, false)
+		if _, exists := groupSigs[group]; !exists {
+			groupOrder = append(groupOrder, group)
+			groupSigs[group] = make(map[string]bool)
+		}
+		groupSigs[group][sigs[i]] = true
+	}
+	for _, group := range groupOrder {
+		since, disagreeing := tl.disagreeSince[group]
+		switch {
+		case len(groupSigs[group]) > 1 && !disagreeing:
+			tl.disagreeSince[group] = now
+			tl.Events = append(tl.Events, TimelineEvent{Time: now, Dig: group, Change: "nameservers disagree"})
+		case len(groupSigs[group]) == 1 && disagreeing:
+			delete(tl.disagreeSince, group)
+			tl.Events = append(tl.Events, TimelineEvent{
+				Time:   now,
+				Dig:    group,
+				Change: fmt.Sprintf("nameservers agree again after %s", now.Sub(since).Round(time.Second)),
+			})
+		}
+	}
+
+	if tl.maxEvents > 0 && len(tl.Events) > tl.maxEvents {
+		tl.Elided += len(tl.Events) - tl.maxEvents
+		tl.Events = tl.Events[len(tl.Events)-tl.maxEvents:]
+	}
+}
+
+func printTimeline(t table.Writer, events []TimelineEvent) {
+	t.AppendHeader(table.Row{"Time", "Dig", "Change"})
+	for _, e := range events {
+		t.AppendRow(table.Row{e.Time.Format(time.TimeOnly), e.Dig, e.Change})
+	}
+}
+
+// recordRound counts a watch round's answers and errors in the window it finished in
+func recordRound(c *counter.BucketedStringSliceCounter, at time.Time, r dig.DigRepeatResult) {
+	for _, ans := range r.Answers {
+		c.AddN(at, ans.StringSlice, ans.Count)
+	}
+	for _, err := range r.Errors {
+		c.AddN(at, dig.ErrorAnswers(err.Category), err.Count)
+	}
+}
+
+// watch digs every interval until interrupted, redrawing the results and the timeline of changes each round
+func watch(parsed parsedCmdCtx) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	ticker := time.NewTicker(parsed.Watch)
+	defer ticker.Stop()
+
+	timeline := NewTimeline(parsed.WatchTimelineLength)
+	var overTime []counter.BucketedStringSliceCounter
+	if parsed.Interval > 0 {
+		start := time.Now()
+		for range parsed.DigRepeatParams {
+			overTime = append(overTime, counter.NewBucketedStringSliceCounter(start, parsed.Interval))
+		}
+	}
+	for {
+		roundCtx, cancel := context.WithTimeout(ctx, parsed.GlobalTimeout)
+		results := dig.DigRepeatParallel(roundCtx, parsed.DigRepeatParams, parsed.Dig)
+		if ctx.Err() != nil {
+			// interrupted mid round, so these results are just cancellation errors
+			cancel()
+			return nil
+		}
+		now := time.Now()
+		timeline.Record(now, parsed.DigRepeatParams, results, parsed.QnameToIP)
+		for i := range overTime {
+			recordRound(&overTime[i], now, results[i])
+			results[i].AnswersOverTime = overTime[i].AsSortedBuckets()
+		}
+
+		fmt.Fprint(parsed.Stdout, clearScreen)
+		fmt.Fprintf(parsed.Stdout, "Every %s. Last dug at %s. Press Ctrl-C to stop\n", parsed.Watch, now.Format(time.TimeOnly))
+		printResults(roundCtx, parsed, results)
+		cancel()
+		if parsed.Interval > 0 {
+			if err := printAnswersOverTime(parsed, results); err != nil {
+				return err
+			}
+		}
+
+		if len(timeline.Events) > 0 {
+			t := table.NewWriter()
+			t.SetStyle(table.StyleRounded)
+			t.SetOutputMirror(parsed.Stdout)
+			t.SetTitle("Timeline")
+			if timeline.Elided > 0 {
+				t.SetTitle(fmt.Sprintf("Timeline (%d earlier changes elided)", timeline.Elided))
+			}
+			printTimeline(t, timeline.Events)
+			t.Render()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
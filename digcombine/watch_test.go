@@ -0,0 +1,127 @@
+package digcombine
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.bbkane.com/shovel/counter"
+	"go.bbkane.com/shovel/dig"
+)
+
+func TestTimelineRecord(t *testing.T) {
+	t.Parallel()
+
+	params := dig.CombineDigRepeatParams(
+		[]string{"198.51.100.1:53", "198.51.100.2:53"},
+		"udp",
+		[]string{"www.example.com"},
+		[]uint16{1},
+		[]netip.Prefix{{}},
+		1,
+	)
+	result := func(answer string) dig.DigRepeatResult {
+		return dig.DigRepeatResult{
+			Answers:         []counter.StringSliceCount{{StringSlice: []string{answer}, Count: 1}},
+			AnswerTTLs:      nil,
+			Errors:          nil,
+			Latency:         dig.LatencyStats{Min: 0, P50: 0, P90: 0, P99: 0, Max: 0},
+			AnswersOverTime: nil,
+		}
+	}
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tl := NewTimeline(0)
+
+	tl.Record(start, params, []dig.DigRepeatResult{result("1.1.1.1"), result("1.1.1.1")}, nil)
+	require.Empty(t, tl.Events)
+
+	// the first nameserver flips
+	tl.Record(start.Add(10*time.Second), params, []dig.DigRepeatResult{result("2.2.2.2"), result("1.1.1.1")}, nil)
+	// the second catches up
+	tl.Record(start.Add(40*time.Second), params, []dig.DigRepeatResult{result("2.2.2.2"), result("2.2.2.2")}, nil)
+
+	require.Equal(
+		t,
+		[]TimelineEvent{
+			{Time: start.Add(10 * time.Second), Dig: "www.example.com A 198.51.100.1:53", Change: "1.1.1.1 -> 2.2.2.2"},
+			{Time: start.Add(10 * time.Second), Dig: "www.example.com A", Change: "nameservers disagree"},
+			{Time: start.Add(40 * time.Second), Dig: "www.example.com A 198.51.100.2:53", Change: "1.1.1.1 -> 2.2.2.2"},
+			{Time: start.Add(40 * time.Second), Dig: "www.example.com A", Change: "nameservers agree again after 30s"},
+		},
+		tl.Events,
+	)
+}
+
+func TestTimelineRecordDistribution(t *testing.T) {
+	t.Parallel()
+
+	params := dig.CombineDigRepeatParams(
+		[]string{"198.51.100.1:53"},
+		"udp",
+		[]string{"www.example.com"},
+		[]uint16{1},
+		[]netip.Prefix{{}},
+		10,
+	)
+	result := func(a int, b int) dig.DigRepeatResult {
+		return dig.DigRepeatResult{
+			Answers: []counter.StringSliceCount{
+				{StringSlice: []string{"1.1.1.1"}, Count: a},
+				{StringSlice: []string{"2.2.2.2"}, Count: b},
+			},
+			AnswerTTLs:      nil,
+			Errors:          nil,
+			Latency:         dig.LatencyStats{Min: 0, P50: 0, P90: 0, P99: 0, Max: 0},
+			AnswersOverTime: nil,
+		}
+	}
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tl := NewTimeline(5)
+	tl.Record(start, params, []dig.DigRepeatResult{result(7, 3)}, nil)
+	// same answers, shifted weights
+	tl.Record(start.Add(10*time.Second), params, []dig.DigRepeatResult{result(3, 7)}, nil)
+	require.Equal(
+		t,
+		[]TimelineEvent{
+			{Time: start.Add(10 * time.Second), Dig: "www.example.com A 198.51.100.1:53", Change: "1.1.1.1 (70%), 2.2.2.2 (30%) -> 1.1.1.1 (30%), 2.2.2.2 (70%)"},
+		},
+		tl.Events,
+	)
+
+	// only the latest events are kept, and the rest are counted
+	for i := range 10 {
+		tl.Record(start.Add(time.Duration(i+2)*10*time.Second), params, []dig.DigRepeatResult{result(7-4*(i%2), 3+4*(i%2))}, nil)
+	}
+	require.Len(t, tl.Events, 5)
+	require.Equal(t, 6, tl.Elided)
+	require.Equal(t, start.Add(110*time.Second), tl.Events[4].Time)
+}
+
+func TestRecordRound(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := counter.NewBucketedStringSliceCounter(start, time.Minute)
+	r := dig.DigRepeatResult{
+		Answers:         []counter.StringSliceCount{{StringSlice: []string{"1.1.1.1"}, Count: 2}},
+		AnswerTTLs:      nil,
+		Errors:          []dig.ErrorCount{{Category: dig.ErrorTimeout, Count: 1, Samples: nil}},
+		Latency:         dig.LatencyStats{Min: 0, P50: 0, P90: 0, P99: 0, Max: 0},
+		AnswersOverTime: nil,
+	}
+
+	recordRound(&c, start.Add(10*time.Second), r)
+	recordRound(&c, start.Add(70*time.Second), r)
+
+	actual := c.AsSortedBuckets()
+	require.Len(t, actual, 2)
+	for _, b := range actual {
+		require.Equal(t, []counter.StringSliceCount{
+			{StringSlice: []string{"1.1.1.1"}, Count: 2},
+			{StringSlice: []string{"error: timeout"}, Count: 1},
+		}, b.Counts)
+	}
+}
@@ -0,0 +1,143 @@
+package digcombine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/miekg/dns"
+	"go.bbkane.com/shovel/dig"
+	"go.bbkane.com/shovel/distribution"
+)
+
+// ParseQnameWeights parses a map of qname to weights like "poolA=70,poolB=30". Every qname must be dug.
+func ParseQnameWeights(qnameToWeights map[string]string, qnames []string) (map[string]map[string]float64, error) {
+	dug := make(map[string]bool)
+	for _, q := range qnames {
+		dug[q] = true
+	}
+	ret := make(map[string]map[string]float64)
+	for qname, weightsStr := range qnameToWeights {
+		if !dug[qname] {
+			return nil, fmt.Errorf("weights passed for a qname that isn't dug: %s", qname)
+		}
+		weights, err := distribution.ParseWeights(weightsStr)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse weights for %s: %w", qname, err)
+		}
+		ret[qname] = weights
+	}
+	return ret, nil
+}
+
+// AnswerSetName names an answer set for weighting: the answer map name if every answer shares one,
+// otherwise the answers joined by spaces.
+func AnswerSetName(answerMap *AnswerMap, answers []string) string {
+	name := ""
+	for i, ans := range answers {
+		ansName, matched := answerMap.Name(ans)
+		if !matched || (i > 0 && ansName != name) {
+			return strings.Join(answers, " ")
+		}
+		name = ansName
+	}
+	if name == "" {
+		return strings.Join(answers, " ")
+	}
+	return name
+}
+
+// WeightCheck is the goodness-of-fit test for one combination with weights
+type WeightCheck struct {
+	Params dig.DigRepeatParams
+	Result distribution.Result
+	// Err is set if the test couldn't run, for example because every dig errored
+	Err error
+}
+
+// CheckWeights tests the answer counts of each combination whose qname (or its IP, for reverse lookups) has weights.
+func CheckWeights(params []dig.DigRepeatParams, results []dig.DigRepeatResult, answerMap *AnswerMap, qnameToIP map[string]string, qnameWeights map[string]map[string]float64) []WeightCheck {
+	checks := []WeightCheck{}
+	for i, p := range params {
+		qname := p.DigOneParams.Qname
+		if ip, isIP := qnameToIP[qname]; isIP {
+			qname = ip
+		}
+		weights, exists := qnameWeights[qname]
+		if !exists {
+			continue
+		}
+		observed := make(map[string]int)
+		for _, ans := range results[i].Answers {
+			observed[AnswerSetName(answerMap, ans.StringSlice)] += ans.Count
+		}
+		res, err := distribution.ChiSquareTest(observed, weights, distribution.DefaultAlpha)
+		checks = append(checks, WeightCheck{Params: p, Result: res, Err: err})
+	}
+	return checks
+}
+
+func printWeightChecks(t table.Writer, parsed parsedCmdCtx, checks []WeightCheck) {
+	//nolint:exhaustruct
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{Name: "Qname", AutoMerge: true},
+		{Name: "Rtype", AutoMerge: true},
+		{Name: "Subnet", AutoMerge: true, Hidden: parsed.DigRepeatParams[0].DigOneParams.SubnetIP == nil},
+		{Name: "Nameserver", AutoMerge: true},
+		{Name: "Answer"},
+		{Name: "Count"},
+		{Name: "Observed"},
+		{Name: "Expected"},
+		{Name: "p-value", AutoMerge: true},
+		{Name: "Result", AutoMerge: true},
+	})
+	t.AppendHeader(table.Row{"Qname", "Rtype", "Subnet", "Nameserver", "Answer", "Count", "Observed", "Expected", "p-value", "Result"})
+
+	fmtPercent := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', 1, 64) + "%"
+	}
+
+	for _, c := range checks {
+		qname := c.Params.DigOneParams.Qname
+		if ip, isIP := parsed.QnameToIP[qname]; isIP {
+			qname = ip
+		}
+		subnet := ""
+		if c.Params.DigOneParams.SubnetIP != nil {
+			subnet = dig.SubnetString(c.Params.DigOneParams.SubnetIP, c.Params.DigOneParams.SubnetBits)
+		}
+		ns := "# " + parsed.NameserverNames[c.Params.DigOneParams.NameserverIPPort] + "\n" + c.Params.DigOneParams.NameserverIPPort
+		rtype := dns.TypeToString[c.Params.DigOneParams.Rtype]
+
+		if c.Err != nil {
+			t.AppendRow(table.Row{qname, rtype, subnet, ns, c.Err.Error(), "", "", "", "", "error"})
+			t.AppendSeparator()
+			continue
+		}
+
+		pValue := strconv.FormatFloat(c.Result.PValue, 'f', 4, 64)
+		result := "pass"
+		if !c.Result.Pass {
+			result = "fail"
+		}
+		if c.Result.LowCounts {
+			result += "\n(too few digs to be reliable)"
+		}
+		for _, b := range c.Result.Buckets {
+			t.AppendRow(table.Row{
+				qname,
+				rtype,
+				subnet,
+				ns,
+				b.Name,
+				b.Observed,
+				fmtPercent(b.ObservedPercent),
+				fmtPercent(b.ExpectedPercent),
+				pValue,
+				result,
+			})
+		}
+		t.AppendSeparator()
+	}
+}
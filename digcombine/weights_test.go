@@ -0,0 +1,80 @@
+package digcombine
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.bbkane.com/shovel/counter"
+	"go.bbkane.com/shovel/dig"
+)
+
+func TestAnswerSetName(t *testing.T) {
+	t.Parallel()
+
+	answerMap, err := ParseAnswerMap(map[string]string{
+		"poolA": "10.0.0.0/24",
+		"poolB": "10.0.1.0/24",
+	})
+	require.Nil(t, err)
+
+	require.Equal(t, "poolA", AnswerSetName(answerMap, []string{"10.0.0.1", "10.0.0.2"}))
+	require.Equal(t, "10.0.0.1 10.0.1.1", AnswerSetName(answerMap, []string{"10.0.0.1", "10.0.1.1"}))
+	require.Equal(t, "10.0.0.1 192.0.2.1", AnswerSetName(answerMap, []string{"10.0.0.1", "192.0.2.1"}))
+	require.Equal(t, "192.0.2.1", AnswerSetName(nil, []string{"192.0.2.1"}))
+}
+
+func TestCheckWeights(t *testing.T) {
+	t.Parallel()
+
+	answerMap, err := ParseAnswerMap(map[string]string{
+		"poolA": "10.0.0.0/24",
+		"poolB": "10.0.1.0/24",
+	})
+	require.Nil(t, err)
+
+	params := dig.CombineDigRepeatParams(
+		[]string{"198.51.100.1:53"},
+		"udp",
+		[]string{"weighted.example.com", "other.example.com"},
+		[]uint16{1},
+		[]netip.Prefix{{}},
+		100,
+	)
+	results := []dig.DigRepeatResult{
+		{
+			Answers: []counter.StringSliceCount{
+				{StringSlice: []string{"10.0.0.1"}, Count: 40},
+				{StringSlice: []string{"10.0.0.2"}, Count: 28},
+				{StringSlice: []string{"10.0.1.1"}, Count: 32},
+			},
+			AnswerTTLs:      []dig.TTLStats{},
+			Errors:          nil,
+			Latency:         dig.LatencyStats{},
+			AnswersOverTime: nil,
+		},
+		{
+			Answers:         nil,
+			AnswerTTLs:      nil,
+			Errors:          nil,
+			Latency:         dig.LatencyStats{},
+			AnswersOverTime: nil,
+		},
+	}
+	weights, err := ParseQnameWeights(
+		map[string]string{"weighted.example.com": "poolA=70,poolB=30"},
+		[]string{"weighted.example.com", "other.example.com"},
+	)
+	require.Nil(t, err)
+
+	checks := CheckWeights(params, results, answerMap, nil, weights)
+	require.Len(t, checks, 1)
+	require.Nil(t, checks[0].Err)
+	require.Equal(t, "weighted.example.com", checks[0].Params.DigOneParams.Qname)
+	require.True(t, checks[0].Result.Pass)
+	require.Equal(t, 68, checks[0].Result.Buckets[0].Observed)
+	require.Equal(t, 32, checks[0].Result.Buckets[1].Observed)
+
+	_, err = ParseQnameWeights(map[string]string{"missing.example.com": "a=1,b=1"}, []string{"weighted.example.com"})
+	require.NotNil(t, err)
+}
@@ -13,19 +13,36 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+type TTL struct {
+	Min       uint32 `yaml:"min"`
+	Max       uint32 `yaml:"max"`
+	Decreased bool   `yaml:"decreased"`
+}
+
 type Rdata struct {
 	Content []string `yaml:"content"`
 	Count   int      `yaml:"count"`
+	TTL     TTL      `yaml:"ttl"`
 }
 
 type Error struct {
-	Count int    `yaml:"count"`
-	Msg   string `yaml:"msg"`
+	Category string   `yaml:"category"`
+	Count    int      `yaml:"count"`
+	Samples  []string `yaml:"samples,omitempty"`
+}
+
+type Latency struct {
+	Min time.Duration `yaml:"min"`
+	P50 time.Duration `yaml:"p50"`
+	P90 time.Duration `yaml:"p90"`
+	P99 time.Duration `yaml:"p99"`
+	Max time.Duration `yaml:"max"`
 }
 
 type Result struct {
-	Rdata  []Rdata `yaml:"rdata"`
-	Errors []Error `yaml:"errors"`
+	Rdata   []Rdata `yaml:"rdata"`
+	Errors  []Error `yaml:"errors"`
+	Latency Latency `yaml:"latency"`
 }
 
 type Return struct {
@@ -58,6 +75,12 @@ func Run(cmdCtx wargcore.Context) error {
 		}
 	}
 
+	for _, qname := range qnames {
+		if err := dig.ValidateQnameTemplate(qname); err != nil {
+			return err
+		}
+	}
+
 	// convert subnet to net.IP:
 	var subnetIPs []net.IP
 	for _, subnet := range subnets {
@@ -84,6 +107,7 @@ func Run(cmdCtx wargcore.Context) error {
 				Proto:            protocols[i],
 				Rtype:            dns.StringToType[rtypes[i]],
 				SubnetIP:         subnetIPs[i],
+				SubnetBits:       dig.FullSubnetBits,
 				Timeout:          timeouts[i],
 			},
 			Count: counts[i],
@@ -104,14 +128,18 @@ func Run(cmdCtx wargcore.Context) error {
 		for r := range dRes[i].Answers {
 			ret.Results[i].Rdata[r].Content = dRes[i].Answers[r].StringSlice
 			ret.Results[i].Rdata[r].Count = dRes[i].Answers[r].Count
+			ret.Results[i].Rdata[r].TTL = TTL(dRes[i].AnswerTTLs[r])
 		}
 
 		ret.Results[i].Errors = make([]Error, len(dRes[i].Errors))
 		for e := range dRes[i].Errors {
-			ret.Results[i].Errors[e].Msg = dRes[i].Errors[e].String
+			ret.Results[i].Errors[e].Category = string(dRes[i].Errors[e].Category)
 			ret.Results[i].Errors[e].Count = dRes[i].Errors[e].Count
+			ret.Results[i].Errors[e].Samples = dRes[i].Errors[e].Samples
 
 		}
+
+		ret.Results[i].Latency = Latency(dRes[i].Latency)
 	}
 
 	encoder := yaml.NewEncoder(os.Stdout)
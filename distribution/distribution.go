@@ -0,0 +1,192 @@
+package distribution
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultAlpha is the significance level: a p-value below this fails the test
+const DefaultAlpha = 0.05
+
+// minExpectedCount is the usual rule of thumb for when the chi-square approximation holds
+const minExpectedCount = 5
+
+// ParseWeights parses weights like "poolA=70,poolB=30". Weights don't need to add up to 100.
+func ParseWeights(s string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for _, entry := range strings.Split(s, ",") {
+		name, weightStr, found := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("weight should look like name=weight: %s", entry)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(weightStr), 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse weight: %s: %w", entry, err)
+		}
+		if weight <= 0 || math.IsInf(weight, 0) || math.IsNaN(weight) {
+			return nil, fmt.Errorf("weight must be positive: %s", entry)
+		}
+		if _, exists := weights[name]; exists {
+			return nil, fmt.Errorf("duplicate weight: %s", name)
+		}
+		weights[name] = weight
+	}
+	return weights, nil
+}
+
+// Bucket compares observed and expected counts for one answer
+type Bucket struct {
+	Name            string
+	Observed        int
+	ObservedPercent float64
+	ExpectedPercent float64
+}
+
+// Result of a chi-square goodness-of-fit test
+type Result struct {
+	// Buckets sorted by name. Answers without a weight have an ExpectedPercent of 0
+	Buckets   []Bucket
+	ChiSquare float64
+	// DegreesOfFreedom is the number of weights - 1
+	DegreesOfFreedom int
+	PValue           float64
+	Pass             bool
+	// LowCounts is true if any expected count is under 5, making the p-value unreliable. Dig more times to fix
+	LowCounts bool
+}
+
+// ChiSquareTest checks whether the observed counts per answer fit the expected weights.
+// Any observed answer without a weight fails the test.
+func ChiSquareTest(observed map[string]int, weights map[string]float64, alpha float64) (Result, error) {
+	if len(weights) < 2 {
+		return Result{}, errors.New("need at least 2 weights to test a distribution") //nolint:exhaustruct
+	}
+
+	total := 0
+	for _, count := range observed {
+		total += count
+	}
+	if total == 0 {
+		return Result{}, errors.New("no answers to test") //nolint:exhaustruct
+	}
+
+	weightTotal := 0.0
+	for _, weight := range weights {
+		weightTotal += weight
+	}
+
+	names := []string{}
+	for name := range weights {
+		names = append(names, name)
+	}
+	unexpected := false
+	for name, count := range observed {
+		if _, exists := weights[name]; !exists && count > 0 {
+			names = append(names, name)
+			unexpected = true
+		}
+	}
+	sort.Strings(names)
+
+	ret := Result{
+		Buckets:          make([]Bucket, 0, len(names)),
+		ChiSquare:        0,
+		DegreesOfFreedom: len(weights) - 1,
+		PValue:           0,
+		Pass:             false,
+		LowCounts:        false,
+	}
+	for _, name := range names {
+		expectedFraction := weights[name] / weightTotal
+		expectedCount := expectedFraction * float64(total)
+		ret.Buckets = append(ret.Buckets, Bucket{
+			Name:            name,
+			Observed:        observed[name],
+			ObservedPercent: 100 * float64(observed[name]) / float64(total),
+			ExpectedPercent: 100 * expectedFraction,
+		})
+		if expectedCount == 0 {
+			continue
+		}
+		if expectedCount < minExpectedCount {
+			ret.LowCounts = true
+		}
+		diff := float64(observed[name]) - expectedCount
+		ret.ChiSquare += diff * diff / expectedCount
+	}
+
+	if unexpected {
+		ret.ChiSquare = math.Inf(1)
+		return ret, nil
+	}
+	ret.PValue = ChiSquarePValue(ret.ChiSquare, ret.DegreesOfFreedom)
+	ret.Pass = ret.PValue >= alpha
+	return ret, nil
+}
+
+// ChiSquarePValue returns the probability of a chi-square statistic at least this large with df degrees of freedom
+func ChiSquarePValue(chiSquare float64, df int) float64 {
+	if math.IsInf(chiSquare, 1) {
+		return 0
+	}
+	return upperIncompleteGammaRegularized(float64(df)/2, chiSquare/2)
+}
+
+// upperIncompleteGammaRegularized computes Q(a, x) = Γ(a, x) / Γ(a) using a series for x < a + 1 and
+// a continued fraction otherwise. See Numerical Recipes 6.2.
+func upperIncompleteGammaRegularized(a float64, x float64) float64 {
+	const maxIterations = 1000
+	const epsilon = 1e-15
+	const tiny = 1e-300
+
+	if x <= 0 {
+		return 1
+	}
+	lgammaA, _ := math.Lgamma(a)
+	// prefactor is x^a * e^-x / Γ(a)
+	prefactor := math.Exp(a*math.Log(x) - x - lgammaA)
+
+	if x < a+1 {
+		// series for the lower P(a, x); Q = 1 - P
+		term := 1 / a
+		sum := term
+		for n := 1; n < maxIterations; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*epsilon {
+				break
+			}
+		}
+		return math.Max(0, 1-sum*prefactor)
+	}
+
+	// modified Lentz's method
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for i := 1; i < maxIterations; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return prefactor * h
+}
@@ -0,0 +1,152 @@
+package distribution
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChiSquarePValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		chiSquare float64
+		df        int
+		expected  float64
+	}{
+		{name: "zero", chiSquare: 0, df: 1, expected: 1},
+		{name: "df1Critical", chiSquare: 3.841458820694124, df: 1, expected: 0.05},
+		// with 2 degrees of freedom, Q = e^(-x/2)
+		{name: "df2", chiSquare: 5.991464547107979, df: 2, expected: 0.05},
+		{name: "df4Series", chiSquare: 1, df: 4, expected: 0.9097959895689501},
+		{name: "df3ContinuedFraction", chiSquare: 11.344866730144373, df: 3, expected: 0.01},
+		{name: "inf", chiSquare: math.Inf(1), df: 1, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.InDelta(t, tt.expected, ChiSquarePValue(tt.chiSquare, tt.df), 1e-9)
+		})
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		s           string
+		expected    map[string]float64
+		expectedErr bool
+	}{
+		{
+			name:        "simple",
+			s:           "poolA=70, poolB=30",
+			expected:    map[string]float64{"poolA": 70, "poolB": 30},
+			expectedErr: false,
+		},
+		{
+			name:        "missingEquals",
+			s:           "poolA",
+			expected:    nil,
+			expectedErr: true,
+		},
+		{
+			name:        "negative",
+			s:           "poolA=-1,poolB=2",
+			expected:    nil,
+			expectedErr: true,
+		},
+		{
+			name:        "duplicate",
+			s:           "poolA=1,poolA=2",
+			expected:    nil,
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := ParseWeights(tt.s)
+			if tt.expectedErr {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestChiSquareTest(t *testing.T) {
+	t.Parallel()
+
+	weights := map[string]float64{"poolA": 70, "poolB": 30}
+
+	tests := []struct {
+		name         string
+		observed     map[string]int
+		expectedPass bool
+		expectedLow  bool
+	}{
+		{
+			name:         "exact",
+			observed:     map[string]int{"poolA": 70, "poolB": 30},
+			expectedPass: true,
+			expectedLow:  false,
+		},
+		{
+			name:         "closeEnough",
+			observed:     map[string]int{"poolA": 65, "poolB": 35},
+			expectedPass: true,
+			expectedLow:  false,
+		},
+		{
+			name:         "evenSplit",
+			observed:     map[string]int{"poolA": 50, "poolB": 50},
+			expectedPass: false,
+			expectedLow:  false,
+		},
+		{
+			name:         "unexpectedAnswer",
+			observed:     map[string]int{"poolA": 70, "poolB": 29, "poolC": 1},
+			expectedPass: false,
+			expectedLow:  false,
+		},
+		{
+			name:         "lowCounts",
+			observed:     map[string]int{"poolA": 7, "poolB": 3},
+			expectedPass: true,
+			expectedLow:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := ChiSquareTest(tt.observed, weights, DefaultAlpha)
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedPass, actual.Pass)
+			require.Equal(t, tt.expectedLow, actual.LowCounts)
+			require.Equal(t, 1, actual.DegreesOfFreedom)
+		})
+	}
+
+	actual, err := ChiSquareTest(map[string]int{"poolA": 65, "poolB": 35}, weights, DefaultAlpha)
+	require.Nil(t, err)
+	require.Equal(
+		t,
+		[]Bucket{
+			{Name: "poolA", Observed: 65, ObservedPercent: 65, ExpectedPercent: 70},
+			{Name: "poolB", Observed: 35, ObservedPercent: 35, ExpectedPercent: 30},
+		},
+		actual.Buckets,
+	)
+
+	_, err = ChiSquareTest(map[string]int{}, weights, DefaultAlpha)
+	require.NotNil(t, err)
+}
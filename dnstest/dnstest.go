@@ -0,0 +1,189 @@
+// Package dnstest runs loopback DNS servers with scripted handlers so tests can exercise real sockets without the internet.
+package dnstest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// listenAttempts is how many free UDP ports to try before giving up on finding one that's also free for TCP
+const listenAttempts = 10
+
+// listen on the same loopback port for UDP and TCP
+func listen() (net.PacketConn, net.Listener, error) {
+	var errs []error
+	for range listenAttempts {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			return nil, nil, err
+		}
+		l, err := net.Listen("tcp", pc.LocalAddr().String())
+		if err == nil {
+			return pc, l, nil
+		}
+		errs = append(errs, err)
+		_ = pc.Close()
+	}
+	return nil, nil, fmt.Errorf("could not listen on the same port for UDP and TCP: %w", errors.Join(errs...))
+}
+
+// Start serves handler on a loopback port over UDP and TCP and returns its IP:port. The servers shut down when the test ends
+func Start(t testing.TB, handler dns.Handler) string {
+	t.Helper()
+
+	pc, l, err := listen()
+	if err != nil {
+		t.Fatalf("dnstest: %v", err)
+	}
+
+	wg := sync.WaitGroup{}
+	servers := []*dns.Server{
+		{PacketConn: pc, Handler: handler}, //nolint:exhaustruct
+		{Listener: l, Handler: handler},    //nolint:exhaustruct
+	}
+	for _, s := range servers {
+		wg.Add(1)
+		s.NotifyStartedFunc = wg.Done
+		go func() {
+			_ = s.ActivateAndServe()
+		}()
+	}
+	wg.Wait()
+
+	t.Cleanup(func() {
+		for _, s := range servers {
+			_ = s.Shutdown()
+		}
+	})
+	return pc.LocalAddr().String()
+}
+
+// reply to r with the rcode and answers
+func reply(w dns.ResponseWriter, r *dns.Msg, rcode int, answers []dns.RR) {
+	m := new(dns.Msg)
+	m.SetRcode(r, rcode)
+	m.Authoritative = true
+	m.Answer = answers
+	_ = w.WriteMsg(m)
+}
+
+// Answer replies to every query with these records in zone file format. Example: "www.example.com. 300 IN A 1.2.3.4".
+// It panics if a record can't be parsed
+func Answer(records ...string) dns.HandlerFunc {
+	rrs := make([]dns.RR, 0, len(records))
+	for _, r := range records {
+		rrs = append(rrs, mustRR(r))
+	}
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		reply(w, r, dns.RcodeSuccess, rrs)
+	}
+}
+
+// Rcode replies to every query with the rcode and no answers
+func Rcode(rcode int) dns.HandlerFunc {
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		reply(w, r, rcode, nil)
+	}
+}
+
+// Drop never replies, so clients time out
+func Drop() dns.HandlerFunc {
+	return func(w dns.ResponseWriter, r *dns.Msg) {}
+}
+
+// Delay waits before passing the query on
+func Delay(d time.Duration, next dns.Handler) dns.HandlerFunc {
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		time.Sleep(d)
+		next.ServeDNS(w, r)
+	}
+}
+
+// Truncate replies to UDP queries with the TC bit set and no answers, and passes TCP queries on
+func Truncate(next dns.Handler) dns.HandlerFunc {
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		if _, isUDP := w.RemoteAddr().(*net.UDPAddr); !isUDP {
+			next.ServeDNS(w, r)
+			return
+		}
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Truncated = true
+		_ = w.WriteMsg(m)
+	}
+}
+
+// Sequence passes the nth query to the nth handler. The last handler gets every query after that
+func Sequence(handlers ...dns.Handler) dns.HandlerFunc {
+	mu := sync.Mutex{}
+	n := 0
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		h := handlers[min(n, len(handlers)-1)]
+		n++
+		mu.Unlock()
+		h.ServeDNS(w, r)
+	}
+}
+
+// Recorder records queries before passing them on
+type Recorder struct {
+	next    dns.Handler
+	mu      sync.Mutex
+	queries []*dns.Msg
+}
+
+// Record queries passed to next
+func Record(next dns.Handler) *Recorder {
+	return &Recorder{next: next, mu: sync.Mutex{}, queries: nil}
+}
+
+// ServeDNS records the query and passes it on
+func (rec *Recorder) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	rec.mu.Lock()
+	rec.queries = append(rec.queries, r.Copy())
+	rec.mu.Unlock()
+	rec.next.ServeDNS(w, r)
+}
+
+// Queries received so far, in order
+func (rec *Recorder) Queries() []*dns.Msg {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	ret := make([]*dns.Msg, 0, len(rec.queries))
+	for _, q := range rec.queries {
+		ret = append(ret, q.Copy())
+	}
+	return ret
+}
+
+// Subnet returns the ECS option of a query, or nil if it doesn't have one
+func Subnet(m *dns.Msg) *dns.EDNS0_SUBNET {
+	opt := m.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+	for _, o := range opt.Option {
+		if ecs, ok := o.(*dns.EDNS0_SUBNET); ok {
+			return ecs
+		}
+	}
+	return nil
+}
+
+func mustRR(s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		panic(fmt.Sprintf("dnstest: could not parse record %q: %v", s, err))
+	}
+	if rr == nil {
+		panic(fmt.Sprintf("dnstest: empty record %q", s))
+	}
+	return rr
+}
@@ -0,0 +1,40 @@
+package dnstest
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStart(t *testing.T) {
+	t.Parallel()
+
+	rec := Record(Sequence(
+		Answer("www.example.com. 300 IN A 192.0.2.1"),
+		Rcode(dns.RcodeServerFailure),
+	))
+	addr := Start(t, rec)
+
+	tests := []struct {
+		net           string
+		expectedRcode int
+		expectedLen   int
+	}{
+		{net: "udp", expectedRcode: dns.RcodeSuccess, expectedLen: 1},
+		{net: "tcp", expectedRcode: dns.RcodeServerFailure, expectedLen: 0},
+		{net: "udp", expectedRcode: dns.RcodeServerFailure, expectedLen: 0},
+	}
+
+	// not parallel: Sequence answers in query order
+	for _, tt := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("www.example.com.", dns.TypeA)
+		client := dns.Client{Net: tt.net} //nolint:exhaustruct
+		in, _, err := client.Exchange(m, addr)
+		require.Nil(t, err)
+		require.Equal(t, tt.expectedRcode, in.Rcode)
+		require.Len(t, in.Answer, tt.expectedLen)
+	}
+	require.Len(t, rec.Queries(), len(tests))
+}
@@ -0,0 +1,245 @@
+package expect
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+	"go.bbkane.com/shovel/dig"
+	"gopkg.in/yaml.v3"
+)
+
+// Expectation of the answers for digs matching its qname and optional rtype, subnet and nameserver.
+// Exactly one of Exact, AnyOf, CIDRs and NXDOMAIN must be set.
+type Expectation struct {
+	Qname string `yaml:"qname"`
+	// Rtype to match. Empty matches every rtype
+	Rtype string `yaml:"rtype"`
+	// Subnet to match, by name or value. Empty matches every subnet
+	Subnet string `yaml:"subnet"`
+	// Nameserver to match, by name or IP:port. Empty matches every nameserver
+	Nameserver string `yaml:"nameserver"`
+
+	// Exact requires every answer set to be exactly these answers, in any order
+	Exact []string `yaml:"exact"`
+	// AnyOf requires every answer to be one of these
+	AnyOf []string `yaml:"any_of"`
+	// CIDRs requires every answer to be an IP in one of these
+	CIDRs []string `yaml:"cidrs"`
+	// NXDOMAIN requires every dig to return NXDOMAIN
+	NXDOMAIN bool `yaml:"nxdomain"`
+
+	cidrs []netip.Prefix
+}
+
+// Name describes what the expectation matches. Example: www.example.com A subnet=china nameserver=google
+func (e Expectation) Name() string {
+	parts := []string{e.Qname}
+	if e.Rtype != "" {
+		parts = append(parts, e.Rtype)
+	}
+	if e.Subnet != "" {
+		parts = append(parts, "subnet="+e.Subnet)
+	}
+	if e.Nameserver != "" {
+		parts = append(parts, "nameserver="+e.Nameserver)
+	}
+	return strings.Join(parts, " ")
+}
+
+// File of expectations
+type File struct {
+	Expectations []Expectation `yaml:"expectations"`
+}
+
+// Load and validate an expectations file
+func Load(path string) (*File, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read expectations file: %w", err)
+	}
+	return Parse(b)
+}
+
+// Parse and validate expectations YAML
+func Parse(b []byte) (*File, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	var f File
+	if err := decoder.Decode(&f); err != nil {
+		return nil, fmt.Errorf("could not parse expectations: %w", err)
+	}
+	if len(f.Expectations) == 0 {
+		return nil, errors.New("no expectations found")
+	}
+	for i := range f.Expectations {
+		e := &f.Expectations[i]
+		if e.Qname == "" {
+			return nil, fmt.Errorf("expectation %d: qname is required", i)
+		}
+		if e.Rtype != "" {
+			if _, exists := dns.StringToType[strings.ToUpper(e.Rtype)]; !exists {
+				return nil, fmt.Errorf("expectation %d: unknown rtype: %s", i, e.Rtype)
+			}
+			e.Rtype = strings.ToUpper(e.Rtype)
+		}
+		set := 0
+		for _, isSet := range []bool{len(e.Exact) > 0, len(e.AnyOf) > 0, len(e.CIDRs) > 0, e.NXDOMAIN} {
+			if isSet {
+				set++
+			}
+		}
+		if set != 1 {
+			return nil, fmt.Errorf("expectation %d (%s): exactly one of exact, any_of, cidrs and nxdomain must be set", i, e.Name())
+		}
+		for _, cidr := range e.CIDRs {
+			prefix, err := netip.ParsePrefix(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("expectation %d (%s): invalid CIDR: %w", i, e.Name(), err)
+			}
+			e.cidrs = append(e.cidrs, prefix.Masked())
+		}
+	}
+	return &f, nil
+}
+
+// Case is one dig combination and its result, with the names shown to the user
+type Case struct {
+	Params dig.DigRepeatParams
+	Result dig.DigRepeatResult
+	// Qname as shown to the user. The IP for reverse lookups
+	Qname          string
+	SubnetName     string
+	NameserverName string
+}
+
+// Name describes the case. Example: www.example.com A 101.251.8.0 8.8.8.8:53
+func (c Case) Name() string {
+	parts := []string{c.Qname, dns.TypeToString[c.Params.DigOneParams.Rtype]}
+	if c.Params.DigOneParams.SubnetIP != nil {
+		parts = append(parts, dig.SubnetString(c.Params.DigOneParams.SubnetIP, c.Params.DigOneParams.SubnetBits))
+	}
+	parts = append(parts, c.Params.DigOneParams.NameserverIPPort)
+	return strings.Join(parts, " ")
+}
+
+func (e Expectation) matches(c Case) bool {
+	if e.Qname != c.Qname && dns.Fqdn(e.Qname) != dns.Fqdn(c.Params.DigOneParams.Qname) {
+		return false
+	}
+	if e.Rtype != "" && e.Rtype != dns.TypeToString[c.Params.DigOneParams.Rtype] {
+		return false
+	}
+	if e.Subnet != "" && e.Subnet != c.SubnetName && e.Subnet != dig.SubnetString(c.Params.DigOneParams.SubnetIP, c.Params.DigOneParams.SubnetBits) {
+		return false
+	}
+	if e.Nameserver != "" && e.Nameserver != c.NameserverName && e.Nameserver != c.Params.DigOneParams.NameserverIPPort {
+		return false
+	}
+	return true
+}
+
+// failures returns why the case doesn't meet the expectation. Empty means it passed
+func (e Expectation) failures(c Case) []string {
+	ret := []string{}
+
+	if e.NXDOMAIN {
+		for _, a := range c.Result.Answers {
+			ret = append(ret, fmt.Sprintf("expected NXDOMAIN, got answer %s (%d times)", strings.Join(a.StringSlice, ", "), a.Count))
+		}
+		for _, err := range c.Result.Errors {
+			if err.Category != dig.ErrorNXDomain {
+				ret = append(ret, fmt.Sprintf("expected NXDOMAIN, got error %s (%d times)", err, err.Count))
+			}
+		}
+		return ret
+	}
+
+	for _, err := range c.Result.Errors {
+		ret = append(ret, fmt.Sprintf("unexpected error %s (%d times)", err, err.Count))
+	}
+
+	for _, a := range c.Result.Answers {
+		switch {
+		case len(e.Exact) > 0:
+			expected := slices.Clone(e.Exact)
+			actual := slices.Clone(a.StringSlice)
+			slices.Sort(expected)
+			slices.Sort(actual)
+			if !slices.Equal(expected, actual) {
+				ret = append(ret, fmt.Sprintf("expected exactly %s, got %s (%d times)", strings.Join(expected, ", "), strings.Join(actual, ", "), a.Count))
+			}
+		case len(e.AnyOf) > 0:
+			for _, ans := range a.StringSlice {
+				if !slices.Contains(e.AnyOf, ans) {
+					ret = append(ret, fmt.Sprintf("unexpected answer %s (%d times)", ans, a.Count))
+				}
+			}
+		case len(e.cidrs) > 0:
+			for _, ans := range a.StringSlice {
+				addr, err := netip.ParseAddr(ans)
+				inCIDRs := err == nil && slices.ContainsFunc(e.cidrs, func(p netip.Prefix) bool { return p.Contains(addr) })
+				if !inCIDRs {
+					ret = append(ret, fmt.Sprintf("answer %s not in %s (%d times)", ans, strings.Join(e.CIDRs, ", "), a.Count))
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// Outcome of checking one case against one expectation
+type Outcome struct {
+	Expectation Expectation
+	// Case is nil if the expectation didn't match any dig
+	Case     *Case
+	Failures []string
+}
+
+func (o Outcome) Passed() bool {
+	return len(o.Failures) == 0
+}
+
+// Name of the outcome for reports
+func (o Outcome) Name() string {
+	if o.Case == nil {
+		return o.Expectation.Name()
+	}
+	return o.Case.Name()
+}
+
+// Evaluate every expectation against the cases it matches.
+// An expectation that doesn't match any case fails, as it's probably a typo.
+func Evaluate(f *File, cases []Case) []Outcome {
+	ret := []Outcome{}
+	for _, e := range f.Expectations {
+		matched := false
+		for i := range cases {
+			if !e.matches(cases[i]) {
+				continue
+			}
+			matched = true
+			ret = append(ret, Outcome{Expectation: e, Case: &cases[i], Failures: e.failures(cases[i])})
+		}
+		if !matched {
+			ret = append(ret, Outcome{Expectation: e, Case: nil, Failures: []string{"expectation didn't match any dig"}})
+		}
+	}
+	return ret
+}
+
+// CountFailed outcomes
+func CountFailed(outcomes []Outcome) int {
+	failed := 0
+	for _, o := range outcomes {
+		if !o.Passed() {
+			failed++
+		}
+	}
+	return failed
+}
@@ -0,0 +1,157 @@
+package expect
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+	"go.bbkane.com/shovel/counter"
+	"go.bbkane.com/shovel/dig"
+)
+
+func newCase(qname string, rtype uint16, subnet net.IP, nameserver string, answers []counter.StringSliceCount, errs []dig.ErrorCount) Case {
+	p := dig.EmptyDigOneparams()
+	p.Qname = qname
+	p.Rtype = rtype
+	p.SubnetIP = subnet
+	p.NameserverIPPort = nameserver
+	return Case{
+		Params: dig.DigRepeatParams{DigOneParams: p, Count: 1, Interval: 0},
+		Result: dig.DigRepeatResult{
+			Answers:         answers,
+			AnswerTTLs:      nil,
+			Errors:          errs,
+			Latency:         dig.LatencyStats{Min: 0, P50: 0, P90: 0, P99: 0, Max: 0},
+			AnswersOverTime: nil,
+		},
+		Qname:          qname,
+		SubnetName:     "",
+		NameserverName: "google",
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		yaml        string
+		expectedErr bool
+	}{
+		{
+			name: "valid",
+			yaml: `expectations:
+  - qname: www.example.com
+    rtype: a
+    exact: [1.2.3.4]
+  - qname: gone.example.com
+    nxdomain: true
+`,
+			expectedErr: false,
+		},
+		{
+			name: "twoKinds",
+			yaml: `expectations:
+  - qname: www.example.com
+    exact: [1.2.3.4]
+    any_of: [1.2.3.4]
+`,
+			expectedErr: true,
+		},
+		{
+			name: "noKind",
+			yaml: `expectations:
+  - qname: www.example.com
+`,
+			expectedErr: true,
+		},
+		{
+			name: "badCIDR",
+			yaml: `expectations:
+  - qname: www.example.com
+    cidrs: [10.0.0.0/33]
+`,
+			expectedErr: true,
+		},
+		{
+			name: "unknownField",
+			yaml: `expectations:
+  - qname: www.example.com
+    exactly: [1.2.3.4]
+`,
+			expectedErr: true,
+		},
+		{
+			name:        "empty",
+			yaml:        `expectations: []`,
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := Parse([]byte(tt.yaml))
+			if tt.expectedErr {
+				require.NotNil(t, err)
+			} else {
+				require.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	t.Parallel()
+
+	f, err := Parse([]byte(`expectations:
+  - qname: www.example.com
+    rtype: A
+    nameserver: google
+    exact: [1.2.3.4, 1.2.3.5]
+  - qname: cdn.example.com
+    cidrs: [10.0.0.0/8]
+  - qname: api.example.com
+    any_of: [1.1.1.1, 2.2.2.2]
+  - qname: gone.example.com
+    nxdomain: true
+  - qname: typo.example.com
+    nxdomain: true
+`))
+	require.Nil(t, err)
+
+	cases := []Case{
+		newCase("www.example.com", dns.TypeA, nil, "8.8.8.8:53", []counter.StringSliceCount{{StringSlice: []string{"1.2.3.5", "1.2.3.4"}, Count: 2}}, nil),
+		newCase("www.example.com", dns.TypeAAAA, nil, "8.8.8.8:53", []counter.StringSliceCount{{StringSlice: []string{"::1"}, Count: 2}}, nil),
+		newCase("cdn.example.com", dns.TypeA, nil, "8.8.8.8:53", []counter.StringSliceCount{{StringSlice: []string{"10.1.1.1", "192.0.2.1"}, Count: 1}}, nil),
+		newCase("api.example.com", dns.TypeA, nil, "8.8.8.8:53", []counter.StringSliceCount{{StringSlice: []string{"2.2.2.2"}, Count: 1}}, []dig.ErrorCount{{Category: dig.ErrorTimeout, Count: 1, Samples: []string{"exchange err: i/o timeout"}}}),
+		newCase("gone.example.com", dns.TypeA, nil, "8.8.8.8:53", nil, []dig.ErrorCount{{Category: dig.ErrorNXDomain, Count: 3, Samples: nil}}),
+	}
+
+	outcomes := Evaluate(f, cases)
+	require.Len(t, outcomes, 5)
+
+	require.Equal(t, "www.example.com A 8.8.8.8:53", outcomes[0].Name())
+	require.True(t, outcomes[0].Passed())
+
+	require.Equal(t, []string{"answer 192.0.2.1 not in 10.0.0.0/8 (1 times)"}, outcomes[1].Failures)
+
+	require.Equal(t, []string{"unexpected error timeout (exchange err: i/o timeout) (1 times)"}, outcomes[2].Failures)
+
+	require.True(t, outcomes[3].Passed())
+
+	require.Nil(t, outcomes[4].Case)
+	require.Equal(t, []string{"expectation didn't match any dig"}, outcomes[4].Failures)
+
+	require.Equal(t, 3, CountFailed(outcomes))
+
+	b := strings.Builder{}
+	err = WriteJUnit(&b, outcomes, 1500*time.Millisecond)
+	require.Nil(t, err)
+	require.Contains(t, b.String(), `<testsuite name="shovel" tests="5" failures="3" time="1.500">`)
+	require.Contains(t, b.String(), `<testcase name="typo.example.com" classname="typo.example.com" time="0">`)
+	require.Contains(t, b.String(), `<failure message="expectation didn&#39;t match any dig">`)
+}
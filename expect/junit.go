@@ -0,0 +1,70 @@
+package expect
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+// WriteJUnit writes outcomes as JUnit XML for CI systems. Each outcome is a test case in a suite named "shovel"
+func WriteJUnit(w io.Writer, outcomes []Outcome, elapsed time.Duration) error {
+	suite := junitTestSuite{
+		XMLName:   xml.Name{Space: "", Local: "testsuite"},
+		Name:      "shovel",
+		Tests:     len(outcomes),
+		Failures:  CountFailed(outcomes),
+		Time:      fmt.Sprintf("%.3f", elapsed.Seconds()),
+		TestCases: make([]junitTestCase, 0, len(outcomes)),
+	}
+	for _, o := range outcomes {
+		tc := junitTestCase{
+			Name:      o.Name(),
+			Classname: o.Expectation.Name(),
+			Time:      "0",
+			Failure:   nil,
+		}
+		if !o.Passed() {
+			tc.Failure = &junitFailure{
+				Message: o.Failures[0],
+				Text:    strings.Join(o.Failures, "\n"),
+			}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("could not write junit: %w", err)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suite); err != nil {
+		return fmt.Errorf("could not write junit: %w", err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("could not write junit: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// DB annotates IPs with country, region and ASN from a local MaxMind format (.mmdb) database,
+// such as GeoLite2-City or GeoLite2-ASN. No network access is needed.
+// A nil *DB is valid and annotates nothing.
+type DB struct {
+	reader *maxminddb.Reader
+}
+
+// Open a .mmdb file
+func Open(path string) (*DB, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open geoip db: %s: %w", path, err)
+	}
+	return &DB{reader: reader}, nil
+}
+
+func (db *DB) Close() error {
+	if db == nil {
+		return nil
+	}
+	return db.reader.Close()
+}
+
+// record holds the fields we use from the City, Country and ASN database types.
+// Fields missing from a database are left empty.
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+	ASN   uint   `maxminddb:"autonomous_system_number"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+}
+
+// Info about an IP. Empty fields weren't found in the database
+type Info struct {
+	// Country ISO code. Example: US
+	Country string
+	// Region is the ISO code of the largest subdivision. Example: CA
+	Region string
+	ASN    uint
+	ASOrg  string
+}
+
+// String formats the info compactly. Example: US/CA AS15169 GOOGLE
+func (i Info) String() string {
+	parts := []string{}
+	location := i.Country
+	if i.Region != "" {
+		location += "/" + i.Region
+	}
+	if location != "" {
+		parts = append(parts, location)
+	}
+	if i.ASN != 0 {
+		parts = append(parts, fmt.Sprintf("AS%d", i.ASN))
+	}
+	if i.ASOrg != "" {
+		parts = append(parts, i.ASOrg)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Lookup an IP. A nil *DB finds nothing
+func (db *DB) Lookup(ip net.IP) (Info, error) {
+	if db == nil {
+		return Info{Country: "", Region: "", ASN: 0, ASOrg: ""}, nil
+	}
+	var r record
+	if err := db.reader.Lookup(ip, &r); err != nil {
+		return Info{Country: "", Region: "", ASN: 0, ASOrg: ""}, fmt.Errorf("could not lookup %s: %w", ip, err)
+	}
+	info := Info{
+		Country: r.Country.ISOCode,
+		Region:  "",
+		ASN:     r.ASN,
+		ASOrg:   r.ASOrg,
+	}
+	if len(r.Subdivisions) > 0 {
+		info.Region = r.Subdivisions[0].ISOCode
+	}
+	return info, nil
+}
+
+// Annotate returns Info.String() for s if it's an IP found in the database, and "" otherwise.
+// Non-IP answers like CNAME targets are ignored.
+func (db *DB) Annotate(s string) string {
+	if db == nil {
+		return ""
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return ""
+	}
+	info, err := db.Lookup(ip)
+	if err != nil {
+		return ""
+	}
+	return info.String()
+}
@@ -0,0 +1,155 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// mmdbEncoder writes the MaxMind DB data section format.
+// See https://maxmind.github.io/MaxMind-DB/
+type mmdbEncoder struct {
+	bytes.Buffer
+}
+
+func (e *mmdbEncoder) control(typeNum int, size int) {
+	// sizes of 29 or more spill into the byte after the control byte(s). This
+	// encoder only needs sizes up to 284
+	sizeBits := size
+	if size >= 29 {
+		sizeBits = 29
+	}
+	if typeNum <= 7 {
+		e.WriteByte(byte(typeNum<<5 | sizeBits))
+	} else {
+		// extended type
+		e.WriteByte(byte(sizeBits))
+		e.WriteByte(byte(typeNum - 7))
+	}
+	if size >= 29 {
+		e.WriteByte(byte(size - 29))
+	}
+}
+
+func (e *mmdbEncoder) str(s string) {
+	e.control(2, len(s))
+	e.WriteString(s)
+}
+
+func (e *mmdbEncoder) uint(typeNum int, v uint64) {
+	b := binary.BigEndian.AppendUint64(nil, v)
+	b = bytes.TrimLeft(b, "\x00")
+	e.control(typeNum, len(b))
+	e.Write(b)
+}
+
+func (e *mmdbEncoder) mapHeader(size int) {
+	e.control(7, size)
+}
+
+func (e *mmdbEncoder) arrayHeader(size int) {
+	e.control(11, size)
+}
+
+// writeTestMMDB writes an IPv4 database with a single record for 1.2.3.0/24
+func writeTestMMDB(t *testing.T) string {
+	t.Helper()
+
+	const nodeCount = 24
+	const dataSectionSeparator = 16
+
+	var data mmdbEncoder
+	data.mapHeader(4)
+	data.str("country")
+	data.mapHeader(1)
+	data.str("iso_code")
+	data.str("US")
+	data.str("subdivisions")
+	data.arrayHeader(1)
+	data.mapHeader(1)
+	data.str("iso_code")
+	data.str("CA")
+	data.str("autonomous_system_number")
+	data.uint(6, 64496)
+	data.str("autonomous_system_organization")
+	data.str("EXAMPLE")
+
+	// one node per bit of the /24. The branch off the path holds "no data" (nodeCount)
+	var tree bytes.Buffer
+	writeRecord := func(v uint32) {
+		tree.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+	}
+	network := uint32(1<<24 | 2<<16 | 3<<8)
+	for i := 0; i < nodeCount; i++ {
+		next := uint32(i + 1)
+		if i == nodeCount-1 {
+			next = nodeCount + dataSectionSeparator // pointer to offset 0 in the data section
+		}
+		if network&(1<<(31-i)) == 0 {
+			writeRecord(next)
+			writeRecord(nodeCount)
+		} else {
+			writeRecord(nodeCount)
+			writeRecord(next)
+		}
+	}
+
+	var metadata mmdbEncoder
+	metadata.mapHeader(9)
+	metadata.str("binary_format_major_version")
+	metadata.uint(5, 2)
+	metadata.str("binary_format_minor_version")
+	metadata.uint(5, 0)
+	metadata.str("build_epoch")
+	metadata.uint(9, 1)
+	metadata.str("database_type")
+	metadata.str("Test")
+	metadata.str("description")
+	metadata.mapHeader(0)
+	metadata.str("ip_version")
+	metadata.uint(5, 4)
+	metadata.str("languages")
+	metadata.arrayHeader(0)
+	metadata.str("node_count")
+	metadata.uint(6, nodeCount)
+	metadata.str("record_size")
+	metadata.uint(5, 24)
+
+	var file bytes.Buffer
+	file.Write(tree.Bytes())
+	file.Write(make([]byte, dataSectionSeparator))
+	file.Write(data.Bytes())
+	file.WriteString("\xab\xcd\xefMaxMind.com")
+	file.Write(metadata.Bytes())
+
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	require.Nil(t, os.WriteFile(path, file.Bytes(), 0o600))
+	return path
+}
+
+func TestDB(t *testing.T) {
+	t.Parallel()
+
+	db, err := Open(writeTestMMDB(t))
+	require.Nil(t, err)
+	defer db.Close()
+
+	info, err := db.Lookup(net.ParseIP("1.2.3.4"))
+	require.Nil(t, err)
+	require.Equal(t, Info{Country: "US", Region: "CA", ASN: 64496, ASOrg: "EXAMPLE"}, info)
+
+	require.Equal(t, "US/CA AS64496 EXAMPLE", db.Annotate("1.2.3.4"))
+	require.Equal(t, "", db.Annotate("5.6.7.8"))
+	require.Equal(t, "", db.Annotate("www.example.com."))
+
+	var nilDB *DB
+	require.Equal(t, "", nilDB.Annotate("1.2.3.4"))
+	info, err = nilDB.Lookup(net.ParseIP("1.2.3.4"))
+	require.Nil(t, err)
+	require.Equal(t, Info{Country: "", Region: "", ASN: 0, ASOrg: ""}, info)
+}
@@ -4,9 +4,12 @@ import (
 	"net/netip"
 	"time"
 
+	"go.bbkane.com/shovel/bench"
 	"go.bbkane.com/shovel/digcombine"
 	"go.bbkane.com/shovel/diglist"
+	"go.bbkane.com/shovel/propagation"
 	"go.bbkane.com/shovel/serve"
+	"go.bbkane.com/shovel/servedns"
 	"go.bbkane.com/warg"
 	"go.bbkane.com/warg/command"
 	"go.bbkane.com/warg/config/yamlreader"
@@ -38,18 +41,27 @@ func digCombineCmd(digFooter string) wargcore.Command {
 		),
 		command.NewFlag(
 			"--qname",
-			"Qualified names to dig",
+			"Qualified names to dig. IP addresses and CIDR ranges are converted to reverse lookup names and can only be dug with --rtype PTR. Supports brace expansion (web{1..40}.example.com, {api,cdn}.example.com), @file to read newline-separated names from a file, and - to read them from stdin. {{rand}} and {{uuid}} placeholders are replaced with fresh random values for every query to bypass resolver caches",
 			slice.String(),
 			flag.ConfigPath("dig.combine.qnames"),
 			flag.Required(),
 			flag.Alias("-q"),
 		),
+		command.NewFlag(
+			"--max-combinations",
+			"Fail instead of digging if the number of qname/rtype/subnet/nameserver combinations exceeds this",
+			scalar.Int(
+				scalar.Default(1000),
+			),
+			flag.ConfigPath("dig.combine.max-combinations"),
+			flag.Required(),
+		),
 		command.NewFlag(
 			"--rtype",
 			"Record types",
 			slice.String(
 				slice.Default([]string{"A"}),
-				slice.Choices("A", "AAAA", "CNAME", "MX", "NS", "TXT"),
+				slice.Choices("A", "AAAA", "CNAME", "MX", "NS", "PTR", "TXT"),
 			),
 			flag.ConfigPath("dig.combine.rtypes"),
 			flag.Required(),
@@ -70,9 +82,21 @@ func digCombineCmd(digFooter string) wargcore.Command {
 			dict.String(),
 			flag.ConfigPath("dig.combine.nameserver-map"),
 		),
+		command.NewFlag(
+			"--tsig-key-map",
+			"Map of TSIG key name to <algorithm>:<base64 secret>. Example: mykey: hmac-sha256:c2VjcmV0",
+			dict.String(),
+			flag.ConfigPath("dig.combine.tsig-key-map"),
+		),
+		command.NewFlag(
+			"--nameserver-tsig-map",
+			"Map of nameserver name (from --nameserver-map) or IP:port to the TSIG key name (from --tsig-key-map) to sign queries with",
+			dict.String(),
+			flag.ConfigPath("dig.combine.nameserver-tsig-map"),
+		),
 		command.NewFlag(
 			"--subnet",
-			"Optional client subnet. Example: 101.251.8.0 for China. Set to 'all' to use everything in --subnet-map",
+			"Optional client subnet. Example: 101.251.8.0 for China. Pass a CIDR like 10.0.0.0/8 to send a partial subnet or sweep it with --subnet-step. Set to 'all' to use everything in --subnet-map",
 			slice.String(),
 			flag.ConfigPath("dig.combine.subnets"),
 			flag.Alias("-s"),
@@ -84,6 +108,14 @@ func digCombineCmd(digFooter string) wargcore.Command {
 			dict.Addr(),
 			flag.ConfigPath("dig.combine.subnet-map"),
 		),
+		command.NewFlag(
+			"--subnet-step",
+			"Sweep each --subnet CIDR in blocks of this prefix length, sending a client subnet query per block. Adjacent blocks with identical answers are collapsed into ranges. Example: --subnet 0.0.0.0/0 --subnet-step 8",
+			scalar.Int(
+				scalar.Default(0),
+			),
+			flag.ConfigPath("dig.combine.subnet-step"),
+		),
 		command.NewFlag(
 			"--global-timeout",
 			"Timeout for combined DNS requests",
@@ -104,6 +136,150 @@ func digCombineCmd(digFooter string) wargcore.Command {
 			flag.Alias("-p"),
 			flag.ConfigPath("dig.combine.protocol"),
 		),
+		command.NewFlag(
+			"--show-latency",
+			"Show min/p50/p90/p99/max response latency for each combination",
+			scalar.Bool(
+				scalar.Default(false),
+			),
+			flag.ConfigPath("dig.combine.show-latency"),
+		),
+		command.NewFlag(
+			"--show-ttl",
+			"Show the min-max TTL of each answer set. TTLs that count down between repeats are marked as cached",
+			scalar.Bool(
+				scalar.Default(false),
+			),
+			flag.ConfigPath("dig.combine.show-ttl"),
+		),
+		command.NewFlag(
+			"--cache-snoop",
+			"Send non-recursive queries (RD bit cleared) to check whether nameservers have each qname cached without triggering resolution. Implies --show-ttl",
+			scalar.Bool(
+				scalar.Default(false),
+			),
+			flag.ConfigPath("dig.combine.cache-snoop"),
+		),
+		command.NewFlag(
+			"--ordered-answers",
+			"Count answers in the order nameservers return them instead of sorting them, so rotated answer sets (like round robin A records) are counted separately",
+			scalar.Bool(
+				scalar.Default(false),
+			),
+			flag.ConfigPath("dig.combine.ordered-answers"),
+		),
+		command.NewFlag(
+			"--elements",
+			"Also count each individual answer across repeats, with its share of responses and, with --ordered-answers, its average position in the answer set. Useful when nameservers return a different subset of answers every time",
+			scalar.Bool(
+				scalar.Default(false),
+			),
+			flag.ConfigPath("dig.combine.elements"),
+		),
+		command.NewFlag(
+			"--ptr-confirm",
+			"For PTR lookups of IP qnames, resolve each PTR target back to addresses with the same nameserver and flag targets that don't resolve to the original IP",
+			scalar.Bool(
+				scalar.Default(false),
+			),
+			flag.ConfigPath("dig.combine.ptr-confirm"),
+		),
+		command.NewFlag(
+			"--geoip-db",
+			"Path to a local MaxMind format (.mmdb) database, such as GeoLite2-City or GeoLite2-ASN. Annotates subnets and answer IPs with country, region and ASN",
+			scalar.Path(),
+			flag.ConfigPath("dig.combine.geoip-db"),
+		),
+		command.NewFlag(
+			"--answer-map",
+			"Map of name to answer IP, CIDR or hostname pattern (*.lb.example.com). Matching answers are shown as 'name (answer)' and answers matching no entry are highlighted",
+			dict.String(),
+			flag.ConfigPath("dig.combine.answer-map"),
+		),
+		command.NewFlag(
+			"--weights",
+			"Map of qname to expected answer weights. Example: www.example.com=poolA=70,poolB=30 . Weight names are --answer-map names, or answers joined by spaces. Prints a chi-square goodness-of-fit test (alpha 0.05) of the observed answer counts. Use a large --count for reliable results",
+			dict.String(),
+			flag.ConfigPath("dig.combine.weights"),
+		),
+		command.NewFlag(
+			"--expect",
+			"Path to a YAML file of expected answers per qname (and optionally rtype, subnet and nameserver). Each expectation sets one of exact, any_of, cidrs or nxdomain. Prints mismatches and exits non-zero if any fail",
+			scalar.Path(),
+			flag.ConfigPath("dig.combine.expect"),
+		),
+		command.NewFlag(
+			"--junit",
+			"Path to write --expect results to as JUnit XML",
+			scalar.Path(),
+			flag.ConfigPath("dig.combine.junit"),
+		),
+		command.NewFlag(
+			"--watch",
+			"Redig on this interval until Ctrl-C, redrawing the table in place with a timeline of when each combination's answers changed and when nameservers disagreed. Example: 10s",
+			scalar.Duration(),
+			flag.ConfigPath("dig.combine.watch"),
+		),
+		command.NewFlag(
+			"--watch-timeline-length",
+			"Only show this many of the latest --watch timeline changes, noting how many earlier ones were elided. 0 shows them all",
+			scalar.Int(
+				scalar.Default(0),
+			),
+			flag.ConfigPath("dig.combine.watch-timeline-length"),
+		),
+		command.NewFlag(
+			"--interval",
+			"Bucket answers into windows of this length and print how each combination's answer distribution changed over the run. Use a high --count to cover a ramp, or --watch to bucket each round. Example: 10s",
+			scalar.Duration(),
+			flag.ConfigPath("dig.combine.interval"),
+		),
+		command.NewFlag(
+			"--interval-format",
+			"How to print answers over time with --interval",
+			scalar.String(
+				scalar.Choices("sparkline", "table", "json"),
+				scalar.Default("sparkline"),
+			),
+			flag.ConfigPath("dig.combine.interval-format"),
+			flag.Required(),
+		),
+		command.NewFlag(
+			"--group-nameservers",
+			"Group results by qname/rtype/subnet and show the consensus answer, the agreement percentage and the outlier nameservers instead of every combination",
+			scalar.Bool(
+				scalar.Default(false),
+			),
+			flag.ConfigPath("dig.combine.group-nameservers"),
+		),
+		command.NewFlag(
+			"--only-disagreements",
+			"Only show qname/rtype/subnets where nameservers disagree",
+			scalar.Bool(
+				scalar.Default(false),
+			),
+			flag.ConfigPath("dig.combine.only-disagreements"),
+		),
+		command.NewFlag(
+			"--save-baseline",
+			"Path to save the params and results to, for a later --compare-baseline",
+			scalar.Path(),
+			flag.ConfigPath("dig.combine.save-baseline"),
+		),
+		command.NewFlag(
+			"--compare-baseline",
+			"Path to a baseline saved with --save-baseline. Reports answers that appeared, disappeared or changed in frequency for each combination, and exits non-zero if anything changed. The baseline's qnames, rtypes, subnets, nameservers, protocol, count, --cache-snoop and --ordered-answers are dug again instead of the ones from flags. Signed combinations need their TSIG key passed again",
+			scalar.Path(),
+			flag.ConfigPath("dig.combine.compare-baseline"),
+		),
+		command.NewFlag(
+			"--baseline-tolerance",
+			"Percentage points an answer's share of digs can move before --compare-baseline reports it as changed",
+			scalar.Int(
+				scalar.Default(10),
+			),
+			flag.ConfigPath("dig.combine.baseline-tolerance"),
+		),
 	)
 }
 
@@ -122,7 +298,7 @@ func digListCmd(digFooter string) wargcore.Command {
 		),
 		command.NewFlag(
 			"--qname",
-			"qualified names to dig",
+			"qualified names to dig. {{rand}} and {{uuid}} placeholders are replaced with fresh random values for every query",
 			slice.String(),
 			flag.ConfigPath("dig.list[].qname"),
 			flag.Required(),
@@ -151,7 +327,7 @@ func digListCmd(digFooter string) wargcore.Command {
 			"--rtype",
 			"Record types",
 			slice.String(
-				slice.Choices("A", "AAAA", "CNAME", "MX", "NS", "TXT"),
+				slice.Choices("A", "AAAA", "CNAME", "MX", "NS", "PTR", "TXT"),
 			),
 			flag.ConfigPath("dig.list[].rtype"),
 			flag.Required(),
@@ -176,6 +352,198 @@ func digListCmd(digFooter string) wargcore.Command {
 	)
 }
 
+func digPropagationCmd(digFooter string) wargcore.Command {
+	return command.New(
+		"Poll resolvers until they all return the expected answers or a deadline passes, then report time-to-converge and stragglers",
+		propagation.Run,
+		command.Footer(digFooter),
+		command.NewFlag(
+			"--qname",
+			"Qualified name to dig",
+			scalar.String(),
+			flag.ConfigPath("dig.propagation.qname"),
+			flag.Required(),
+			flag.Alias("-q"),
+		),
+		command.NewFlag(
+			"--rtype",
+			"Record type",
+			scalar.String(
+				scalar.Default("A"),
+				scalar.Choices("A", "AAAA", "CNAME", "MX", "NS", "PTR", "TXT"),
+			),
+			flag.ConfigPath("dig.propagation.rtype"),
+			flag.Required(),
+			flag.Alias("-r"),
+		),
+		command.NewFlag(
+			"--expected",
+			"Expected answers. A resolver has converged when it answers exactly these, in any order",
+			slice.String(),
+			flag.ConfigPath("dig.propagation.expected"),
+			flag.Required(),
+			flag.Alias("-e"),
+		),
+		command.NewFlag(
+			"--nameserver",
+			"Nameserver IP + port to poll. Set to 'all' to use everything in --nameserver-map, or include 'public' to add everything in --public-resolver-map",
+			slice.String(),
+			flag.ConfigPath("dig.propagation.nameservers"),
+			flag.Required(),
+			flag.Alias("-n"),
+			flag.UnsetSentinel("UNSET"),
+		),
+		command.NewFlag(
+			"--nameserver-map",
+			"Map of name to nameserver IP:port. Can then use names as arguments to --nameserver",
+			dict.String(),
+			flag.ConfigPath("dig.propagation.nameserver-map"),
+		),
+		command.NewFlag(
+			"--public-resolver-map",
+			"Map of name to public resolver IP:port to poll for --nameserver public. Defaults to a built-in list of well known public resolvers",
+			dict.String(),
+			flag.ConfigPath("dig.propagation.public-resolver-map"),
+		),
+		command.NewFlag(
+			"--interval",
+			"Time between polls of resolvers that haven't converged",
+			scalar.Duration(
+				scalar.Default(5*time.Second),
+			),
+			flag.ConfigPath("dig.propagation.interval"),
+			flag.Required(),
+		),
+		command.NewFlag(
+			"--deadline",
+			"Stop polling and report stragglers after this long",
+			scalar.Duration(
+				scalar.Default(10*time.Minute),
+			),
+			flag.ConfigPath("dig.propagation.deadline"),
+			flag.Required(),
+		),
+		command.NewFlag(
+			"--protocol",
+			"Protocol to use when digging",
+			scalar.String(
+				scalar.Choices("udp", "udp4", "udp6", "tcp", "tcp4", "tcp6"),
+				scalar.Default("udp"),
+			),
+			flag.Required(),
+			flag.Alias("-p"),
+			flag.ConfigPath("dig.propagation.protocol"),
+		),
+	)
+}
+
+func digBenchCmd(digFooter string) wargcore.Command {
+	return command.New(
+		"Send sustained queries at a target QPS or with closed loop workers and report achieved QPS, outcomes and latency over time",
+		bench.Run,
+		command.Footer(digFooter),
+		command.NewFlag(
+			"--qname",
+			"Qualified names to dig, with an optional relative weight. Example: www.example.com=70 . Supports templates like {{rand}}.example.com",
+			slice.String(),
+			flag.ConfigPath("dig.bench.qnames"),
+			flag.Required(),
+			flag.Alias("-q"),
+		),
+		command.NewFlag(
+			"--rtype",
+			"Record type",
+			scalar.String(
+				scalar.Default("A"),
+				scalar.Choices("A", "AAAA", "CNAME", "MX", "NS", "PTR", "TXT"),
+			),
+			flag.ConfigPath("dig.bench.rtype"),
+			flag.Required(),
+			flag.Alias("-r"),
+		),
+		command.NewFlag(
+			"--nameserver",
+			"Nameserver IP + port to send queries to. Queries are spread evenly across nameservers. Set to 'all' to use everything in --nameserver-map",
+			slice.String(),
+			flag.ConfigPath("dig.bench.nameservers"),
+			flag.Required(),
+			flag.Alias("-n"),
+			flag.UnsetSentinel("UNSET"),
+		),
+		command.NewFlag(
+			"--nameserver-map",
+			"Map of name to nameserver IP:port. Can then use names as arguments to --nameserver",
+			dict.String(),
+			flag.ConfigPath("dig.bench.nameserver-map"),
+		),
+		command.NewFlag(
+			"--qps",
+			"Queries per second to send. 0 runs closed loop, where each worker sends its next query as soon as the last one returns",
+			scalar.Int(
+				scalar.Default(0),
+			),
+			flag.ConfigPath("dig.bench.qps"),
+			flag.Required(),
+		),
+		command.NewFlag(
+			"--workers",
+			"Closed loop workers, or the maximum queries in flight when --qps is set. Queries over that limit are dropped and counted",
+			scalar.Int(
+				scalar.Default(10),
+			),
+			flag.ConfigPath("dig.bench.workers"),
+			flag.Required(),
+			flag.Alias("-w"),
+		),
+		command.NewFlag(
+			"--duration",
+			"How long to send queries",
+			scalar.Duration(
+				scalar.Default(10*time.Second),
+			),
+			flag.ConfigPath("dig.bench.duration"),
+			flag.Required(),
+			flag.Alias("-d"),
+		),
+		command.NewFlag(
+			"--interval",
+			"Group results over time into intervals this long",
+			scalar.Duration(
+				scalar.Default(time.Second),
+			),
+			flag.ConfigPath("dig.bench.interval"),
+			flag.Required(),
+		),
+		command.NewFlag(
+			"--timeout",
+			"Timeout for each DNS request",
+			scalar.Duration(
+				scalar.Default(2*time.Second),
+			),
+			flag.ConfigPath("dig.bench.timeout"),
+			flag.Required(),
+		),
+		command.NewFlag(
+			"--protocol",
+			"Protocol to use when digging",
+			scalar.String(
+				scalar.Choices("udp", "udp4", "udp6", "tcp", "tcp4", "tcp6"),
+				scalar.Default("udp"),
+			),
+			flag.Required(),
+			flag.Alias("-p"),
+			flag.ConfigPath("dig.bench.protocol"),
+		),
+		command.NewFlag(
+			"--subnet",
+			"Client subnet IP to send with each query",
+			scalar.String(),
+			flag.ConfigPath("dig.bench.subnet"),
+			flag.Alias("-s"),
+		),
+	)
+}
+
 func serveCmd(digFooter string) wargcore.Command {
 	return command.New(
 		"Run dig commands remotely",
@@ -198,6 +566,21 @@ func serveCmd(digFooter string) wargcore.Command {
 			),
 			flag.ConfigPath("serve.footer"),
 		),
+		command.NewFlag(
+			"--max-combinations",
+			"Reject submissions where the number of qname/rtype/subnet/nameserver combinations exceeds this",
+			scalar.Int(
+				scalar.Default(1000),
+			),
+			flag.ConfigPath("serve.max-combinations"),
+			flag.Required(),
+		),
+		command.NewFlag(
+			"--geoip-db",
+			"Path to a local MaxMind format (.mmdb) database, such as GeoLite2-City or GeoLite2-ASN. Annotates subnets and answer IPs with country, region and ASN",
+			scalar.Path(),
+			flag.ConfigPath("serve.geoip-db"),
+		),
 		command.NewFlag(
 			"--https-certfile",
 			"Path to HTTP public key in PEM format. NOTE: in most cases, this is the leaf cert concatenated with the ICA in one file, and the order matters",
@@ -283,6 +666,30 @@ func serveCmd(digFooter string) wargcore.Command {
 	)
 }
 
+func serveDNSCmd() wargcore.Command {
+	return command.New(
+		"Run a local authoritative DNS server from a YAML zone file, with GeoDNS-style answers by ECS subnet or source IP, weighted answers, latency and injected SERVFAILs and timeouts",
+		servedns.Run,
+		command.NewFlag(
+			"--addr-port",
+			"Address + Port to serve DNS from, over UDP and TCP",
+			scalar.AddrPort(
+				scalar.Default(netip.MustParseAddrPort("127.0.0.1:5353")),
+			),
+			flag.Required(),
+			flag.ConfigPath("serve-dns.addr-port"),
+		),
+		command.NewFlag(
+			"--zone",
+			"Path to YAML zone file",
+			scalar.Path(),
+			flag.Required(),
+			flag.ConfigPath("serve-dns.zone"),
+			flag.Alias("-z"),
+		),
+	)
+}
+
 func buildApp() *wargcore.App {
 	digFooter := `Homepage: https://github.com/bbkane/shovel
 Examples: https://github.com/bbkane/shovel/blob/master/examples.md
@@ -297,11 +704,19 @@ Examples: https://github.com/bbkane/shovel/blob/master/examples.md
 				"serve",
 				serveCmd(digFooter),
 			),
+			section.Command(
+				"serve-dns",
+				serveDNSCmd(),
+			),
 			section.CommandMap(warg.VersionCommandMap()),
 			section.Footer(digFooter),
 			section.NewSection(
 				"dig",
 				"Dig in different ways",
+				section.Command(
+					"bench",
+					digBenchCmd(digFooter),
+				),
 				section.Command(
 					"combine",
 					digCombineCmd(digFooter),
@@ -310,6 +725,10 @@ Examples: https://github.com/bbkane/shovel/blob/master/examples.md
 					"list",
 					digListCmd(digFooter),
 				),
+				section.Command(
+					"propagation",
+					digPropagationCmd(digFooter),
+				),
 			),
 		),
 		warg.ConfigFlag(
@@ -45,7 +45,7 @@ func TestRunCLI(t *testing.T) {
 			digOneFunc: dig.DigOneFuncMock(
 				context.Background(),
 				[]dig.DigOneResult{
-					{Answers: []string{"1.2.3.4"}, Err: nil},
+					{Answers: []string{"1.2.3.4"}, TTLs: nil, RTT: 0, Err: nil},
 				},
 			),
 		},
@@ -62,8 +62,8 @@ func TestRunCLI(t *testing.T) {
 			digOneFunc: dig.DigOneFuncMock(
 				context.Background(),
 				[]dig.DigOneResult{
-					{Answers: []string{"1.2.3.4"}, Err: nil},
-					{Answers: []string{"1.2.3.4"}, Err: nil},
+					{Answers: []string{"1.2.3.4"}, TTLs: nil, RTT: 0, Err: nil},
+					{Answers: []string{"1.2.3.4"}, TTLs: nil, RTT: 0, Err: nil},
 				},
 			),
 		},
@@ -0,0 +1,130 @@
+package propagation
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/conc/iter"
+	"go.bbkane.com/shovel/dig"
+)
+
+// PublicResolvers returns well known public resolvers by name. It's the default --public-resolver-map,
+// so --nameserver public works offline without any config.
+func PublicResolvers() map[string]string {
+	return map[string]string{
+		"adguard":       "94.140.14.14:53",
+		"cleanbrowsing": "185.228.168.9:53",
+		"cloudflare":    "1.1.1.1:53",
+		"cloudflare-2":  "1.0.0.1:53",
+		"comodo":        "8.26.56.26:53",
+		"google":        "8.8.8.8:53",
+		"google-2":      "8.8.4.4:53",
+		"level3":        "4.2.2.2:53",
+		"opendns":       "208.67.222.222:53",
+		"opendns-2":     "208.67.220.220:53",
+		"quad9":         "9.9.9.9:53",
+		"quad9-2":       "149.112.112.112:53",
+		"yandex":        "77.88.8.8:53",
+	}
+}
+
+// Params to poll resolvers with
+type Params struct {
+	// DigOneParams is the query to send. NameserverIPPort is replaced by each of Nameservers
+	DigOneParams dig.DigOneParams
+	// Nameservers as IP:port
+	Nameservers []string
+	// Expected answers. A resolver has converged when it answers exactly these, in any order
+	Expected []string
+	// Interval between polls of resolvers that haven't converged
+	Interval time.Duration
+	// Deadline to stop polling at
+	Deadline time.Duration
+}
+
+// ResolverStatus is the outcome of polling one resolver
+type ResolverStatus struct {
+	Nameserver string
+	Converged  bool
+	// ConvergedAfter is the time from the start of polling to the first poll that returned the expected answers
+	ConvergedAfter time.Duration
+	Polls          int
+	// LastAnswers and LastErr are from the latest poll
+	LastAnswers []string
+	LastErr     error
+}
+
+// normalizeAnswers lowercases, strips trailing dots and sorts so "WWW.example.com." matches "www.example.com"
+func normalizeAnswers(answers []string) []string {
+	ret := make([]string, 0, len(answers))
+	for _, a := range answers {
+		ret = append(ret, strings.TrimSuffix(strings.ToLower(a), "."))
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Poll queries every nameserver each interval until it returns the expected answers or the deadline passes.
+// onRound is called after each round with the statuses so far, for progress output. It may be nil.
+// Statuses are returned in the order of p.Nameservers.
+func Poll(ctx context.Context, digOne dig.DigOneFunc, p Params, onRound func(elapsed time.Duration, statuses []ResolverStatus)) []ResolverStatus {
+	ctx, cancel := context.WithTimeout(ctx, p.Deadline)
+	defer cancel()
+
+	expected := normalizeAnswers(p.Expected)
+
+	statuses := make([]ResolverStatus, len(p.Nameservers))
+	for i, ns := range p.Nameservers {
+		statuses[i] = ResolverStatus{
+			Nameserver:     ns,
+			Converged:      false,
+			ConvergedAfter: 0,
+			Polls:          0,
+			LastAnswers:    nil,
+			LastErr:        nil,
+		}
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		iter.ForEachIdx(statuses, func(i int, s *ResolverStatus) {
+			if s.Converged {
+				return
+			}
+			digOneParams := p.DigOneParams
+			digOneParams.NameserverIPPort = s.Nameserver
+			res := digOne(ctx, digOneParams)
+			if ctx.Err() != nil {
+				// the deadline passed mid-poll. Don't count it
+				return
+			}
+			s.Polls++
+			s.LastAnswers = res.Answers
+			s.LastErr = res.Err
+			if res.Err == nil && slices.Equal(normalizeAnswers(res.Answers), expected) {
+				s.Converged = true
+				s.ConvergedAfter = time.Since(start)
+			}
+		})
+		if onRound != nil {
+			onRound(time.Since(start), statuses)
+		}
+
+		allConverged := !slices.ContainsFunc(statuses, func(s ResolverStatus) bool { return !s.Converged })
+		if allConverged {
+			return statuses
+		}
+
+		select {
+		case <-ctx.Done():
+			return statuses
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package propagation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.bbkane.com/shovel/dig"
+)
+
+func TestPoll(t *testing.T) {
+	t.Parallel()
+
+	// fast converges on the second poll, slow never does
+	var mu sync.Mutex
+	polls := make(map[string]int)
+	digOne := func(ctx context.Context, p dig.DigOneParams) dig.DigOneResult {
+		mu.Lock()
+		defer mu.Unlock()
+		polls[p.NameserverIPPort]++
+		if p.NameserverIPPort == "198.51.100.1:53" && polls[p.NameserverIPPort] >= 2 {
+			return dig.DigOneResult{Answers: []string{"2.2.2.2", "1.1.1.1"}, TTLs: nil, RTT: 0, Err: nil}
+		}
+		if p.NameserverIPPort == "198.51.100.3:53" {
+			return dig.DigOneResult{Answers: nil, TTLs: nil, RTT: 0, Err: errors.New("i/o timeout")}
+		}
+		return dig.DigOneResult{Answers: []string{"1.1.1.1"}, TTLs: nil, RTT: 0, Err: nil}
+	}
+
+	p := dig.EmptyDigOneparams()
+	p.Qname = "www.example.com"
+	p.Rtype = 1
+	statuses := Poll(
+		context.Background(),
+		digOne,
+		Params{
+			DigOneParams: p,
+			Nameservers:  []string{"198.51.100.1:53", "198.51.100.2:53", "198.51.100.3:53"},
+			Expected:     []string{"1.1.1.1", "2.2.2.2"},
+			Interval:     10 * time.Millisecond,
+			Deadline:     100 * time.Millisecond,
+		},
+		nil,
+	)
+
+	require.Len(t, statuses, 3)
+
+	require.True(t, statuses[0].Converged)
+	require.Equal(t, 2, statuses[0].Polls)
+
+	require.False(t, statuses[1].Converged)
+	require.Greater(t, statuses[1].Polls, 2)
+	require.Equal(t, []string{"1.1.1.1"}, statuses[1].LastAnswers)
+
+	require.False(t, statuses[2].Converged)
+	require.EqualError(t, statuses[2].LastErr, "i/o timeout")
+}
+
+func TestExpandPublic(t *testing.T) {
+	t.Parallel()
+
+	passed, nameserverMap := ExpandPublic([]string{"mine"}, map[string]string{"mine": "192.0.2.1:53"}, PublicResolvers())
+	require.Equal(t, []string{"mine"}, passed)
+	require.Equal(t, map[string]string{"mine": "192.0.2.1:53"}, nameserverMap)
+
+	passed, nameserverMap = ExpandPublic([]string{"mine", "public"}, map[string]string{"mine": "192.0.2.1:53", "google": "192.0.2.2:53"}, PublicResolvers())
+	require.Equal(t, "mine", passed[0])
+	require.Len(t, passed, 1+len(PublicResolvers()))
+	require.Equal(t, "192.0.2.2:53", nameserverMap["google"])
+	require.Equal(t, "1.1.1.1:53", nameserverMap["cloudflare"])
+
+	// a configured list replaces the built in one
+	passed, nameserverMap = ExpandPublic([]string{"public"}, nil, map[string]string{"corp": "192.0.2.3:53", "corp-2": "192.0.2.4:53"})
+	require.Equal(t, []string{"corp", "corp-2"}, passed)
+	require.Equal(t, map[string]string{"corp": "192.0.2.3:53", "corp-2": "192.0.2.4:53"}, nameserverMap)
+}
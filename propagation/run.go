@@ -0,0 +1,151 @@
+package propagation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/miekg/dns"
+	"go.bbkane.com/shovel/dig"
+	"go.bbkane.com/shovel/digcombine"
+	"go.bbkane.com/warg/wargcore"
+)
+
+// ExpandPublic replaces "public" in passed nameservers with the names of publicResolvers and adds them to the nameserver map.
+// Entries already in nameserverMap win over public ones with the same name.
+func ExpandPublic(passedNameservers []string, nameserverMap map[string]string, publicResolvers map[string]string) ([]string, map[string]string) {
+	if !slices.Contains(passedNameservers, "public") {
+		return passedNameservers, nameserverMap
+	}
+	merged := maps.Clone(publicResolvers)
+	maps.Copy(merged, nameserverMap)
+
+	publicNames := slices.Sorted(maps.Keys(publicResolvers))
+	expanded := []string{}
+	for _, ns := range passedNameservers {
+		if ns == "public" {
+			expanded = append(expanded, publicNames...)
+			continue
+		}
+		expanded = append(expanded, ns)
+	}
+	return expanded, merged
+}
+
+func printStatuses(t table.Writer, statuses []ResolverStatus, nameserverNames map[string]string) {
+	// converged resolvers in the order they converged, then stragglers
+	sorted := slices.Clone(statuses)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Converged != sorted[j].Converged {
+			return sorted[i].Converged
+		}
+		return sorted[i].ConvergedAfter < sorted[j].ConvergedAfter
+	})
+
+	t.AppendHeader(table.Row{"Nameserver", "Status", "Converged After", "Polls", "Last Ans/Err"})
+	for _, s := range sorted {
+		status := "straggler"
+		convergedAfter := ""
+		if s.Converged {
+			status = "converged"
+			convergedAfter = s.ConvergedAfter.Round(time.Second).String()
+		}
+		last := strings.Join(s.LastAnswers, "\n")
+		if s.LastErr != nil {
+			last = s.LastErr.Error()
+		}
+		t.AppendRow(table.Row{
+			"# " + nameserverNames[s.Nameserver] + "\n" + s.Nameserver,
+			status,
+			convergedAfter,
+			s.Polls,
+			last,
+		})
+		t.AppendSeparator()
+	}
+}
+
+func Run(cmdCtx wargcore.Context) error {
+	qname := cmdCtx.Flags["--qname"].(string)
+	rtypeStr := cmdCtx.Flags["--rtype"].(string)
+	expected := cmdCtx.Flags["--expected"].([]string)
+	interval := cmdCtx.Flags["--interval"].(time.Duration)
+	deadline := cmdCtx.Flags["--deadline"].(time.Duration)
+	proto := cmdCtx.Flags["--protocol"].(string)
+
+	if interval <= 0 {
+		return errors.New("--interval must be positive")
+	}
+
+	rtypes, err := digcombine.ConvertRTypes([]string{rtypeStr})
+	if err != nil {
+		return err
+	}
+
+	nameserverMap, _ := cmdCtx.Flags["--nameserver-map"].(map[string]string)
+	passedNameservers := cmdCtx.Flags["--nameserver"].([]string)
+	publicResolvers, _ := cmdCtx.Flags["--public-resolver-map"].(map[string]string)
+	if len(publicResolvers) == 0 {
+		publicResolvers = PublicResolvers()
+	}
+	passedNameservers, nameserverMap = ExpandPublic(passedNameservers, nameserverMap, publicResolvers)
+	nameservers, nameserverNames, err := digcombine.ParseNameservers(passedNameservers, nameserverMap)
+	if err != nil {
+		return err
+	}
+
+	var digOneFunc dig.DigOneFunc = dig.DigOne
+	if replacementDigOneFunc := cmdCtx.Context.Value(dig.DigOneFuncCtxKey{}); replacementDigOneFunc != nil {
+		digOneFunc = replacementDigOneFunc.(dig.DigOneFunc)
+	}
+
+	digOneParams := dig.EmptyDigOneparams()
+	digOneParams.Qname = qname
+	digOneParams.Rtype = rtypes[0]
+	digOneParams.Proto = proto
+
+	statuses := Poll(
+		context.Background(),
+		digOneFunc,
+		Params{
+			DigOneParams: digOneParams,
+			Nameservers:  nameservers,
+			Expected:     expected,
+			Interval:     interval,
+			Deadline:     deadline,
+		},
+		func(elapsed time.Duration, statuses []ResolverStatus) {
+			converged := 0
+			for _, s := range statuses {
+				if s.Converged {
+					converged++
+				}
+			}
+			fmt.Fprintf(cmdCtx.Stdout, "%s: %d/%d resolvers converged\n", elapsed.Round(time.Second), converged, len(statuses))
+		},
+	)
+
+	t := table.NewWriter()
+	t.SetStyle(table.StyleRounded)
+	t.SetOutputMirror(cmdCtx.Stdout)
+	t.SetTitle(qname + " " + dns.TypeToString[rtypes[0]] + " -> " + strings.Join(expected, ", "))
+	printStatuses(t, statuses, nameserverNames)
+	t.Render()
+
+	stragglers := 0
+	for _, s := range statuses {
+		if !s.Converged {
+			stragglers++
+		}
+	}
+	if stragglers > 0 {
+		return fmt.Errorf("%d of %d resolvers didn't converge within %s", stragglers, len(statuses), deadline)
+	}
+	return nil
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/labstack/gommon/log"
+	"go.bbkane.com/shovel/geoip"
 	"go.bbkane.com/shovel/serve/custommiddleware"
 	"go.bbkane.com/warg/path"
 	"go.bbkane.com/warg/wargcore"
@@ -97,6 +98,17 @@ func Run(cmdCtx wargcore.Context) error {
 	addrPort := cmdCtx.Flags["--addr-port"].(netip.AddrPort).String()
 	motd, _ := cmdCtx.Flags["--motd"].(string)
 	footer, _ := cmdCtx.Flags["--footer"].(string)
+	maxCombinations := cmdCtx.Flags["--max-combinations"].(int)
+
+	var geoIPDB *geoip.DB
+	if geoIPPath, exists := cmdCtx.Flags["--geoip-db"].(path.Path); exists {
+		var err error
+		geoIPDB, err = geoip.Open(geoIPPath.MustExpand())
+		if err != nil {
+			return err
+		}
+		defer geoIPDB.Close()
+	}
 
 	otelProvider := cmdCtx.Flags["--otel-provider"].(string)
 	protocol := cmdCtx.Flags["--protocol"].(string)
@@ -205,7 +217,9 @@ func Run(cmdCtx wargcore.Context) error {
 		Tracer: tp.Tracer(
 			"shovel serve", // TODO: get a better name
 		),
-		Footer: template.HTML(footer),
+		Footer:          template.HTML(footer),
+		MaxCombinations: maxCombinations,
+		GeoIP:           geoIPDB,
 	}
 
 	addRoutes(e, s)
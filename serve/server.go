@@ -15,6 +15,7 @@ import (
 	"github.com/miekg/dns"
 	"go.bbkane.com/shovel/dig"
 	"go.bbkane.com/shovel/digcombine"
+	"go.bbkane.com/shovel/geoip"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
@@ -43,6 +44,12 @@ type server struct {
 	Version string
 
 	Tracer trace.Tracer
+
+	// MaxCombinations of qname/rtype/subnet/nameserver a submission can dig
+	MaxCombinations int
+
+	// GeoIP annotates subnets and answers. nil if --geoip-db isn't passed
+	GeoIP *geoip.DB
 }
 
 func (s *server) Submit(c echo.Context) error {
@@ -51,19 +58,37 @@ func (s *server) Submit(c echo.Context) error {
 	defer cancel()
 
 	countForm := c.FormValue("count")
-	qnames := splitFormValue(c.FormValue("qnames"))
+	unexpandedQnames := splitFormValue(c.FormValue("qnames"))
 	nameservers := splitFormValue(c.FormValue("nameservers"))
 	proto := c.FormValue("protocol")
 	rtypeStrs := splitFormValue(c.FormValue("rtypes"))
 	subnetMapStrs := splitFormValue(c.FormValue("subnetMap"))
 	subnets := splitFormValue(c.FormValue("subnets"))
+	answerMapStrs := splitFormValue(c.FormValue("answerMap"))
+	showLatency := c.FormValue("showLatency") != ""
+	showTTL := c.FormValue("showTTL") != ""
+	onlyDisagreements := c.FormValue("onlyDisagreements") != ""
+	// only showing disagreements needs grouping, as the full table's rowspans can't skip rows
+	groupNameservers := c.FormValue("groupNameservers") != "" || onlyDisagreements
 
 	formErrors := []error{}
 
+	// Don't let requests read files from the server
+	qnames, err := digcombine.ExpandQnames(unexpandedQnames, nil, nil)
+	if err != nil {
+		formErrors = append(formErrors, fmt.Errorf("error expanding qnames: %w", err))
+	}
+
 	if proto != "udp" && proto != "tcp" && proto != "tcp-tls" {
 		formErrors = append(formErrors, errors.New("unsupported proto (should be one of udp, tcp, tcp-tls): "+proto))
 	}
 
+	for _, qname := range qnames {
+		if err := dig.ValidateQnameTemplate(qname); err != nil {
+			formErrors = append(formErrors, err)
+		}
+	}
+
 	count, err := strconv.Atoi(countForm)
 	if err != nil {
 		err := fmt.Errorf("error parsing count: %w", err)
@@ -76,6 +101,11 @@ func (s *server) Submit(c echo.Context) error {
 		formErrors = append(formErrors, err)
 	}
 
+	qnames, qnameToIP, err := digcombine.ReverseQnames(qnames, rtypes)
+	if err != nil {
+		formErrors = append(formErrors, err)
+	}
+
 	subnetMap := make(map[string]net.IP)
 	for _, entry := range subnetMapStrs {
 		name, subnetStr, found := strings.Cut(entry, "=")
@@ -96,6 +126,30 @@ func (s *server) Submit(c echo.Context) error {
 		formErrors = append(formErrors, err)
 	}
 
+	nameToAnswer := make(map[string]string)
+	for _, entry := range answerMapStrs {
+		name, answer, found := strings.Cut(entry, "=")
+		if !found {
+			formErrors = append(formErrors, errors.New("unable to parse answer map entry: "+entry))
+			continue
+		}
+		nameToAnswer[name] = answer
+	}
+	var answerMap *digcombine.AnswerMap
+	if len(nameToAnswer) > 0 {
+		answerMap, err = digcombine.ParseAnswerMap(nameToAnswer)
+		if err != nil {
+			formErrors = append(formErrors, err)
+		}
+	}
+
+	if len(formErrors) == 0 {
+		err := digcombine.CheckCombinationCount(len(qnames), len(rtypes), len(parsedSubnets), len(nameservers), s.MaxCombinations)
+		if err != nil {
+			formErrors = append(formErrors, err)
+		}
+	}
+
 	if len(formErrors) > 0 {
 		return c.Render(http.StatusOK, "submiterror.html", formErrors)
 	}
@@ -139,22 +193,43 @@ func (s *server) Submit(c echo.Context) error {
 		},
 		params,
 		resMul,
+		answerMap,
 	)
 	if err != nil {
 		// TODO: non-fatal error, send to traces and continue...
 		panic(err)
 	}
 
+	// label reverse lookups with the IP they were generated from
+	qnameLabels := []string{}
+	for _, qname := range qnames {
+		if ip, isIP := qnameToIP[qname]; isIP {
+			qname = ip
+		}
+		qnameLabels = append(qnameLabels, qname)
+	}
+
+	nameserverGroups := []NameserverGroupRow{}
+	if groupNameservers {
+		nameserverGroups = buildNameserverGroupRows(params, resMul, qnameToIP, onlyDisagreements)
+	}
+
 	t := ResultTable{
-		FilledFormURL: filledFormURL,
+		FilledFormURL:    filledFormURL,
+		GroupNameservers: groupNameservers,
+		NameserverGroups: nameserverGroups,
 		Rows: buildRows(buildRowParams{
-			Qnames:       qnames,
+			Qnames:       qnameLabels,
 			RtypeStrs:    rtypeStrs,
 			Subnets:      parsedSubnets,
 			Nameservers:  nameservers,
 			ResMul:       resMul,
 			SubnetToName: subnetToName,
+			GeoIP:        s.GeoIP,
+			AnswerMap:    answerMap,
 		}),
+		ShowLatency:         showLatency,
+		ShowTTL:             showTTL,
 		TraceIDTemplateArgs: TraceIDTemplateArgs{TraceID: traceID},
 		TableYAML:           tableYAMLStr,
 	}
@@ -165,13 +240,18 @@ func (s *server) Submit(c echo.Context) error {
 func (s *server) Index(c echo.Context) error {
 
 	type indexData struct {
-		Count       string
-		Qnames      string
-		Nameservers string
-		Proto       string
-		Rtypes      string
-		SubnetMap   string
-		Subnets     string
+		Count             string
+		Qnames            string
+		Nameservers       string
+		Proto             string
+		Rtypes            string
+		SubnetMap         string
+		Subnets           string
+		AnswerMap         string
+		ShowLatency       bool
+		ShowTTL           bool
+		GroupNameservers  bool
+		OnlyDisagreements bool
 
 		Footer     template.HTML
 		Motd       template.HTML
@@ -180,23 +260,28 @@ func (s *server) Index(c echo.Context) error {
 	}
 
 	f := indexData{
-		Count:       c.FormValue("count"),
-		Qnames:      c.FormValue("qnames"),
-		Nameservers: c.FormValue("nameservers"),
-		Proto:       c.FormValue("protocol"),
-		Rtypes:      c.FormValue("rtypes"),
-		SubnetMap:   c.FormValue("subnetMap"),
-		Subnets:     c.FormValue("subnets"),
-		Footer:      s.Footer,
-		Motd:        s.Motd,
-		Version:     s.Version,
+		Count:             c.FormValue("count"),
+		Qnames:            c.FormValue("qnames"),
+		Nameservers:       c.FormValue("nameservers"),
+		Proto:             c.FormValue("protocol"),
+		Rtypes:            c.FormValue("rtypes"),
+		SubnetMap:         c.FormValue("subnetMap"),
+		Subnets:           c.FormValue("subnets"),
+		AnswerMap:         c.FormValue("answerMap"),
+		ShowLatency:       c.FormValue("showLatency") != "",
+		ShowTTL:           c.FormValue("showTTL") != "",
+		GroupNameservers:  c.FormValue("groupNameservers") != "",
+		OnlyDisagreements: c.FormValue("onlyDisagreements") != "",
+		Footer:            s.Footer,
+		Motd:              s.Motd,
+		Version:           s.Version,
 		// Might be better not to hardcode this, but I don't see it changing ever...
 		VersionURL: "https://github.com/bbkane/shovel",
 	}
 	return c.Render(http.StatusOK, "index.html", f)
 }
 
-func (s *server) DigOne(ctx context.Context, p dig.DigOneParams) ([]string, error) {
+func (s *server) DigOne(ctx context.Context, p dig.DigOneParams) dig.DigOneResult {
 	// https://opentelemetry.io/docs/concepts/signals/traces/#spans
 	ctx, span := s.Tracer.Start(
 		ctx,
@@ -206,15 +291,16 @@ func (s *server) DigOne(ctx context.Context, p dig.DigOneParams) ([]string, erro
 			attribute.String("Proto", p.Proto),
 			attribute.String("Qname", p.Qname),
 			attribute.String("Rtype", dns.TypeToString[p.Rtype]),
-			attribute.String("SubnetIP", p.SubnetIP.String()),
+			attribute.String("SubnetIP", dig.SubnetString(p.SubnetIP, p.SubnetBits)),
 			attribute.Int64("Timeout", int64(p.Timeout)),
 		),
 		trace.WithSpanKind(trace.SpanKindInternal),
 	)
 	defer span.End()
-	results, err := dig.DigOne(ctx, p)
-	if err != nil {
-		span.SetStatus(codes.Error, err.Error())
+	res := dig.DigOne(ctx, p)
+	span.SetAttributes(attribute.Int64("RTT", int64(res.RTT)))
+	if res.Err != nil {
+		span.SetStatus(codes.Error, res.Err.Error())
 	}
-	return results, err
+	return res
 }
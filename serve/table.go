@@ -2,21 +2,38 @@ package serve
 
 import (
 	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
 
 	"go.bbkane.com/shovel/dig"
+	"go.bbkane.com/shovel/digcombine"
+	"go.bbkane.com/shovel/geoip"
 )
 
 type TdData struct {
 	Content string
 	Rowspan int
 }
+
+// AnsErr is one answer or error line
+type AnsErr struct {
+	Content string
+	// Unmatched answers don't match any answer map entry
+	Unmatched bool
+}
+
 type AnsErrCount struct {
-	AnsErrs []string
+	AnsErrs []AnsErr
+	TTL     string
 	Count   int
 }
 
 type Row struct {
 	Columns      []TdData
+	Latency      string
 	AnsErrCounts []AnsErrCount
 }
 
@@ -24,9 +41,30 @@ type TraceIDTemplateArgs struct {
 	TraceID string
 }
 
+// NameserverGroupRow summarizes whether nameservers agree for one qname/rtype/subnet
+type NameserverGroupRow struct {
+	Qname     string
+	Rtype     string
+	Subnet    string
+	Agreement string
+	Disagree  bool
+	Consensus []string
+	Outliers  []OutlierRow
+}
+
+type OutlierRow struct {
+	Nameserver string
+	AnsErrs    []string
+}
+
 type ResultTable struct {
-	FilledFormURL       string
+	FilledFormURL string
+	// GroupNameservers shows NameserverGroups instead of Rows
+	GroupNameservers    bool
+	NameserverGroups    []NameserverGroupRow
 	Rows                []Row
+	ShowLatency         bool
+	ShowTTL             bool
 	TraceIDTemplateArgs TraceIDTemplateArgs
 	TableYAML           string
 }
@@ -34,10 +72,40 @@ type ResultTable struct {
 type buildRowParams struct {
 	Qnames       []string
 	RtypeStrs    []string
-	Subnets      []net.IP
+	Subnets      []netip.Prefix
 	Nameservers  []string
 	ResMul       []dig.DigRepeatResult
 	SubnetToName map[string]string
+	GeoIP        *geoip.DB
+	AnswerMap    *digcombine.AnswerMap
+}
+
+// buildNameserverGroupRows summarizes nameserver agreement per qname/rtype/subnet, optionally only keeping disagreements
+func buildNameserverGroupRows(params []dig.DigRepeatParams, results []dig.DigRepeatResult, qnameToIP map[string]string, onlyDisagreements bool) []NameserverGroupRow {
+	rows := []NameserverGroupRow{}
+	for _, g := range digcombine.GroupNameservers(params, results) {
+		if onlyDisagreements && !g.Disagree() {
+			continue
+		}
+		qname := g.Params.DigOneParams.Qname
+		if ip, isIP := qnameToIP[qname]; isIP {
+			qname = ip
+		}
+		outliers := []OutlierRow{}
+		for _, o := range g.Outliers {
+			outliers = append(outliers, OutlierRow{Nameserver: o.Nameserver, AnsErrs: strings.Split(o.Signature, "\n")})
+		}
+		rows = append(rows, NameserverGroupRow{
+			Qname:     qname,
+			Rtype:     dns.TypeToString[g.Params.DigOneParams.Rtype],
+			Subnet:    dig.SubnetString(g.Params.DigOneParams.SubnetIP, g.Params.DigOneParams.SubnetBits),
+			Agreement: strconv.FormatFloat(g.AgreementPercent, 'f', 0, 64) + "%",
+			Disagree:  g.Disagree(),
+			Consensus: strings.Split(g.Consensus, "\n"),
+			Outliers:  outliers,
+		})
+	}
+	return rows
 }
 
 func buildRows(p buildRowParams) []Row {
@@ -73,9 +141,13 @@ func buildRows(p buildRowParams) []Row {
 		i := 0
 		for r := 0; r < rows; r += sWidth {
 			subnet := p.Subnets[i%sLen]
-			content := subnet.String()
-			if subnet != nil {
+			content := "<nil>"
+			if subnet.IsValid() {
+				content = dig.SubnetString(net.IP(subnet.Addr().AsSlice()), subnet.Bits())
 				content = content + " (" + p.SubnetToName[content] + ")"
+				if annotation := p.GeoIP.Annotate(subnet.Addr().String()); annotation != "" {
+					content = content + " " + annotation
+				}
 			}
 			td := TdData{Content: content, Rowspan: sWidth}
 			res[r].Columns = append(res[r].Columns, td)
@@ -93,22 +165,39 @@ func buildRows(p buildRowParams) []Row {
 		}
 	}
 
+	fmtAnswers := func(answers []string) []AnsErr {
+		ret := make([]AnsErr, 0, len(answers))
+		for _, ans := range answers {
+			label, matched := p.AnswerMap.Label(ans)
+			if annotation := p.GeoIP.Annotate(ans); annotation != "" {
+				label += " (" + annotation + ")"
+			}
+			ret = append(ret, AnsErr{Content: label, Unmatched: !matched})
+		}
+		return ret
+	}
+
 	// Add anserrs to table
 	for i, r := range p.ResMul {
 		aecs := []AnsErrCount{}
-		for _, a := range r.Answers {
+		for j, a := range r.Answers {
 			aecs = append(
 				aecs,
-				AnsErrCount{AnsErrs: a.StringSlice, Count: a.Count},
+				AnsErrCount{AnsErrs: fmtAnswers(a.StringSlice), TTL: r.AnswerTTLs[j].String(), Count: a.Count},
 			)
 		}
 		for _, e := range r.Errors {
+			errLines := []AnsErr{}
+			for _, line := range e.Lines() {
+				errLines = append(errLines, AnsErr{Content: line, Unmatched: false})
+			}
 			aecs = append(
 				aecs,
-				AnsErrCount{AnsErrs: []string{e.String}, Count: e.Count},
+				AnsErrCount{AnsErrs: errLines, TTL: "", Count: e.Count},
 			)
 		}
 		res[i].AnsErrCounts = aecs
+		res[i].Latency = r.Latency.String()
 
 	}
 
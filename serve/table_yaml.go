@@ -3,9 +3,11 @@ package serve
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/miekg/dns"
 	"go.bbkane.com/shovel/dig"
+	"go.bbkane.com/shovel/digcombine"
 	"gopkg.in/yaml.v3"
 )
 
@@ -17,15 +19,31 @@ type buildTableYAMLMetadata struct {
 }
 
 // buildTableJSON returns a YAML string suitable so we can copy it from the button. Copied from digList for now... will probably want to improve the format later.
-func buildTableYAML(metadata buildTableYAMLMetadata, dParams []dig.DigRepeatParams, dRes []dig.DigRepeatResult) (string, error) {
+func buildTableYAML(metadata buildTableYAMLMetadata, dParams []dig.DigRepeatParams, dRes []dig.DigRepeatResult, answerMap *digcombine.AnswerMap) (string, error) {
+	type TTL struct {
+		Min       uint32 `yaml:"min"`
+		Max       uint32 `yaml:"max"`
+		Decreased bool   `yaml:"decreased"`
+	}
+
 	type Rdata struct {
 		Content []string `yaml:"content"`
 		Count   int      `yaml:"count"`
+		TTL     TTL      `yaml:"ttl"`
 	}
 
 	type Error struct {
-		Count int    `yaml:"count"`
-		Msg   string `yaml:"msg"`
+		Category string   `yaml:"category"`
+		Count    int      `yaml:"count"`
+		Samples  []string `yaml:"samples,omitempty"`
+	}
+
+	type Latency struct {
+		Min time.Duration `yaml:"min"`
+		P50 time.Duration `yaml:"p50"`
+		P90 time.Duration `yaml:"p90"`
+		P99 time.Duration `yaml:"p99"`
+		Max time.Duration `yaml:"max"`
 	}
 
 	type Result struct {
@@ -37,8 +55,9 @@ func buildTableYAML(metadata buildTableYAMLMetadata, dParams []dig.DigRepeatPara
 		Rtype            string
 		SubnetIP         string
 		// From Results
-		Rdata  []Rdata `yaml:"rdata"`
-		Errors []Error `yaml:"errors"`
+		Rdata   []Rdata `yaml:"rdata"`
+		Errors  []Error `yaml:"errors"`
+		Latency Latency `yaml:"latency"`
 	}
 
 	type Return struct {
@@ -58,19 +77,28 @@ func buildTableYAML(metadata buildTableYAMLMetadata, dParams []dig.DigRepeatPara
 		ret.Results[i].Proto = dParams[i].DigOneParams.Proto
 		ret.Results[i].Qname = dParams[i].DigOneParams.Qname
 		ret.Results[i].Rtype = dns.TypeToString[dParams[i].DigOneParams.Rtype]
-		ret.Results[i].SubnetIP = dParams[i].DigOneParams.SubnetIP.String()
+		ret.Results[i].SubnetIP = dig.SubnetString(dParams[i].DigOneParams.SubnetIP, dParams[i].DigOneParams.SubnetBits)
 
 		for r := range dRes[i].Answers {
-			ret.Results[i].Rdata[r].Content = dRes[i].Answers[r].StringSlice
+			content := make([]string, 0, len(dRes[i].Answers[r].StringSlice))
+			for _, ans := range dRes[i].Answers[r].StringSlice {
+				label, _ := answerMap.Label(ans)
+				content = append(content, label)
+			}
+			ret.Results[i].Rdata[r].Content = content
 			ret.Results[i].Rdata[r].Count = dRes[i].Answers[r].Count
+			ret.Results[i].Rdata[r].TTL = TTL(dRes[i].AnswerTTLs[r])
 		}
 
 		ret.Results[i].Errors = make([]Error, len(dRes[i].Errors))
 		for e := range dRes[i].Errors {
-			ret.Results[i].Errors[e].Msg = dRes[i].Errors[e].String
+			ret.Results[i].Errors[e].Category = string(dRes[i].Errors[e].Category)
 			ret.Results[i].Errors[e].Count = dRes[i].Errors[e].Count
+			ret.Results[i].Errors[e].Samples = dRes[i].Errors[e].Samples
 
 		}
+
+		ret.Results[i].Latency = Latency(dRes[i].Latency)
 	}
 
 	b := strings.Builder{}
@@ -0,0 +1,160 @@
+package servedns
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// clientAddr returns the ECS subnet from the query if it has one, and the client address to match rules against.
+// That's the ECS subnet address, or the source IP without one
+func clientAddr(w dns.ResponseWriter, r *dns.Msg) (*dns.EDNS0_SUBNET, netip.Addr) {
+	if opt := r.IsEdns0(); opt != nil {
+		for _, o := range opt.Option {
+			if ecs, ok := o.(*dns.EDNS0_SUBNET); ok {
+				addr, _ := netip.AddrFromSlice(ecs.Address)
+				return ecs, addr.Unmap()
+			}
+		}
+	}
+	var ip net.IP
+	switch a := w.RemoteAddr().(type) {
+	case *net.UDPAddr:
+		ip = a.IP
+	case *net.TCPAddr:
+		ip = a.IP
+	}
+	addr, _ := netip.AddrFromSlice(ip)
+	return nil, addr.Unmap()
+}
+
+// pick the answers to return: one at random by weight for weighted rules, otherwise all of them
+func (rule *Rule) pick() []dns.RR {
+	if !rule.weighted {
+		ret := make([]dns.RR, 0, len(rule.Answers))
+		for _, a := range rule.Answers {
+			ret = append(ret, dns.Copy(a.rr))
+		}
+		return ret
+	}
+	total := 0.0
+	for _, a := range rule.Answers {
+		total += a.Weight
+	}
+	r := rand.Float64() * total //nolint:gosec // answer distribution, not security
+	for _, a := range rule.Answers {
+		if r < a.Weight {
+			return []dns.RR{dns.Copy(a.rr)}
+		}
+		r -= a.Weight
+	}
+	return []dns.RR{dns.Copy(rule.Answers[len(rule.Answers)-1].rr)}
+}
+
+// ServeDNS answers a query from the zone, so a Zone can be used as a dns.Handler
+func (z *Zone) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	if len(r.Question) != 1 {
+		m.Rcode = dns.RcodeFormatError
+		_ = w.WriteMsg(m)
+		return
+	}
+	q := r.Question[0]
+	name := strings.ToLower(q.Name)
+
+	ecs, client := clientAddr(w, r)
+	if ecs != nil {
+		o := &dns.OPT{
+			Hdr: dns.RR_Header{
+				Name:     ".",
+				Rrtype:   dns.TypeOPT,
+				Class:    dns.DefaultMsgSize,
+				Ttl:      0,
+				Rdlength: 0,
+			},
+			Option: []dns.EDNS0{&dns.EDNS0_SUBNET{
+				Code:          dns.EDNS0SUBNET,
+				Family:        ecs.Family,
+				SourceNetmask: ecs.SourceNetmask,
+				SourceScope:   ecs.SourceNetmask,
+				Address:       ecs.Address,
+			}},
+		}
+		m.Extra = append(m.Extra, o)
+	}
+
+	rec, exists := z.records[recordKey{name: name, rtype: q.Qtype}]
+	if !exists {
+		if !z.names[name] {
+			m.Rcode = dns.RcodeNameError
+		}
+		// Otherwise it's NOERROR with no answers (NODATA)
+		_ = w.WriteMsg(m)
+		return
+	}
+
+	var rule *Rule
+	for i := range rec.Rules {
+		if rec.Rules[i].matches(client) {
+			rule = &rec.Rules[i]
+			break
+		}
+	}
+	if rule == nil {
+		_ = w.WriteMsg(m)
+		return
+	}
+
+	time.Sleep(rule.Latency)
+
+	roll := rand.Float64() //nolint:gosec // failure injection, not security
+	switch {
+	case roll < rule.TimeoutRate:
+		return
+	case roll < rule.TimeoutRate+rule.ServfailRate:
+		m.Rcode = dns.RcodeServerFailure
+	case rule.rcode != dns.RcodeSuccess:
+		m.Rcode = rule.rcode
+	default:
+		m.Answer = rule.pick()
+	}
+	_ = w.WriteMsg(m)
+}
+
+// Serve the handler on UDP and TCP at addr until ctx is done
+func Serve(ctx context.Context, addr string, handler dns.Handler) error {
+	servers := []*dns.Server{
+		{Addr: addr, Net: "udp", Handler: handler}, //nolint:exhaustruct
+		{Addr: addr, Net: "tcp", Handler: handler}, //nolint:exhaustruct
+	}
+
+	errs := make(chan error, len(servers))
+	for _, s := range servers {
+		go func() {
+			errs <- s.ListenAndServe()
+		}()
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-errs:
+	}
+	for _, s := range servers {
+		// Shutting down a server that failed to start returns an error we don't care about
+		_ = s.Shutdown()
+	}
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
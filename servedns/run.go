@@ -0,0 +1,28 @@
+package servedns
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"os/signal"
+
+	"go.bbkane.com/warg/path"
+	"go.bbkane.com/warg/wargcore"
+)
+
+func Run(cmdCtx wargcore.Context) error {
+	zonePath := cmdCtx.Flags["--zone"].(path.Path).MustExpand()
+	addrPort := cmdCtx.Flags["--addr-port"].(netip.AddrPort)
+
+	zone, err := Load(zonePath)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Fprintf(cmdCtx.Stdout, "Serving %d records from %s on %s (UDP and TCP). Press Ctrl+C to stop\n", len(zone.Records), zonePath, addrPort)
+	return Serve(ctx, addrPort.String(), zone)
+}
@@ -0,0 +1,149 @@
+package servedns
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+	"go.bbkane.com/shovel/dnstest"
+)
+
+const testZone = `
+ttl: 60
+records:
+  - name: www.example.com
+    type: A
+    rules:
+      - subnets: [101.251.8.0/24]
+        answers:
+          - value: 2.2.2.2
+      - answers:
+          - value: 1.1.1.1
+          - value: 1.1.1.2
+  - name: weighted.example.com
+    type: A
+    ttl: 30
+    rules:
+      - answers:
+          - value: 3.3.3.3
+            weight: 1
+          - value: 4.4.4.4
+            weight: 0.000001
+  - name: broken.example.com
+    type: A
+    rules:
+      - answers:
+          - value: 5.5.5.5
+        servfail_rate: 1
+  - name: slow.example.com
+    type: A
+    rules:
+      - answers:
+          - value: 6.6.6.6
+        latency: 200ms
+  - name: gone.example.com
+    type: CNAME
+    rules:
+      - rcode: REFUSED
+`
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		zone        string
+		expectedErr bool
+	}{
+		{name: "valid", zone: testZone, expectedErr: false},
+		{name: "noRecords", zone: "ttl: 60\n", expectedErr: true},
+		{name: "unknownField", zone: "records:\n  - name: a.com\n    type: A\n    nope: 1\n", expectedErr: true},
+		{name: "unknownType", zone: "records:\n  - name: a.com\n    type: NOPE\n    rules: [{answers: [{value: 1.1.1.1}]}]\n", expectedErr: true},
+		{name: "badAnswer", zone: "records:\n  - name: a.com\n    type: A\n    rules: [{answers: [{value: not-an-ip}]}]\n", expectedErr: true},
+		{name: "noAnswers", zone: "records:\n  - name: a.com\n    type: A\n    rules: [{latency: 1s}]\n", expectedErr: true},
+		{name: "partialWeights", zone: "records:\n  - name: a.com\n    type: A\n    rules: [{answers: [{value: 1.1.1.1, weight: 1}, {value: 1.1.1.2}]}]\n", expectedErr: true},
+		{name: "rates", zone: "records:\n  - name: a.com\n    type: A\n    rules: [{answers: [{value: 1.1.1.1}], servfail_rate: 0.6, timeout_rate: 0.6}]\n", expectedErr: true},
+		{name: "duplicate", zone: "records:\n  - name: a.com\n    type: A\n    rules: [{rcode: NXDOMAIN}]\n  - name: A.com.\n    type: a\n    rules: [{rcode: NXDOMAIN}]\n", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := Parse([]byte(tt.zone))
+			if tt.expectedErr {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+		})
+	}
+}
+
+func TestServeDNS(t *testing.T) {
+	t.Parallel()
+
+	zone, err := Parse([]byte(testZone))
+	require.Nil(t, err)
+
+	addr := dnstest.Start(t, zone)
+
+	tests := []struct {
+		name          string
+		qname         string
+		qtype         uint16
+		subnet        net.IP
+		expectedRcode int
+		expected      []string
+	}{
+		{name: "default", qname: "www.example.com", qtype: dns.TypeA, subnet: nil, expectedRcode: dns.RcodeSuccess, expected: []string{"1.1.1.1", "1.1.1.2"}},
+		{name: "subnet", qname: "WWW.example.com", qtype: dns.TypeA, subnet: net.ParseIP("101.251.8.0"), expectedRcode: dns.RcodeSuccess, expected: []string{"2.2.2.2"}},
+		{name: "otherSubnet", qname: "www.example.com", qtype: dns.TypeA, subnet: net.ParseIP("8.8.8.0"), expectedRcode: dns.RcodeSuccess, expected: []string{"1.1.1.1", "1.1.1.2"}},
+		{name: "weighted", qname: "weighted.example.com", qtype: dns.TypeA, subnet: nil, expectedRcode: dns.RcodeSuccess, expected: []string{"3.3.3.3"}},
+		{name: "nodata", qname: "www.example.com", qtype: dns.TypeAAAA, subnet: nil, expectedRcode: dns.RcodeSuccess, expected: nil},
+		{name: "nxdomain", qname: "missing.example.com", qtype: dns.TypeA, subnet: nil, expectedRcode: dns.RcodeNameError, expected: nil},
+		{name: "servfail", qname: "broken.example.com", qtype: dns.TypeA, subnet: nil, expectedRcode: dns.RcodeServerFailure, expected: nil},
+		{name: "rcode", qname: "gone.example.com", qtype: dns.TypeCNAME, subnet: nil, expectedRcode: dns.RcodeRefused, expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := new(dns.Msg)
+			m.SetQuestion(dns.Fqdn(tt.qname), tt.qtype)
+			if tt.subnet != nil {
+				m.SetEdns0(dns.DefaultMsgSize, false)
+				opt := m.IsEdns0()
+				opt.Option = append(opt.Option, &dns.EDNS0_SUBNET{
+					Code:          dns.EDNS0SUBNET,
+					Family:        1,
+					SourceNetmask: 24,
+					SourceScope:   0,
+					Address:       tt.subnet,
+				})
+			}
+			in, err := dns.Exchange(m, addr)
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedRcode, in.Rcode)
+
+			var actual []string
+			for _, rr := range in.Answer {
+				actual = append(actual, rr.(*dns.A).A.String())
+			}
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+
+	t.Run("latency", func(t *testing.T) {
+		t.Parallel()
+
+		m := new(dns.Msg)
+		m.SetQuestion("slow.example.com.", dns.TypeA)
+		start := time.Now()
+		_, err := dns.Exchange(m, addr)
+		require.Nil(t, err)
+		require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	})
+}
@@ -0,0 +1,211 @@
+package servedns
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultTTL is used for records when neither the record nor the zone sets a TTL
+const defaultTTL = 300
+
+// Answer to a query. Weighted answers are picked one at a time at random; unweighted answers are all returned together
+type Answer struct {
+	// Value in zone file format. Example: 1.2.3.4 for A or "10 mail.example.com." for MX
+	Value  string  `yaml:"value"`
+	Weight float64 `yaml:"weight"`
+
+	rr dns.RR
+}
+
+// Rule decides how to answer queries from matching clients
+type Rule struct {
+	// Subnets to match against the ECS subnet, or the source IP if the query doesn't have one. Empty matches every client
+	Subnets []string `yaml:"subnets"`
+	Answers []Answer `yaml:"answers"`
+	// Rcode to return instead of answers. Example: NXDOMAIN
+	Rcode string `yaml:"rcode"`
+	// Latency to wait before responding
+	Latency time.Duration `yaml:"latency"`
+	// ServfailRate is the fraction of queries, from 0 to 1, to answer with SERVFAIL
+	ServfailRate float64 `yaml:"servfail_rate"`
+	// TimeoutRate is the fraction of queries, from 0 to 1, to not respond to
+	TimeoutRate float64 `yaml:"timeout_rate"`
+
+	subnets  []netip.Prefix
+	rcode    int
+	weighted bool
+}
+
+// Record is a name and type and the rules to answer it with. The first matching rule is used
+type Record struct {
+	Name  string `yaml:"name"`
+	Type  string `yaml:"type"`
+	TTL   uint32 `yaml:"ttl"`
+	Rules []Rule `yaml:"rules"`
+
+	rtype uint16
+}
+
+type recordKey struct {
+	name  string
+	rtype uint16
+}
+
+// Zone of records to serve
+type Zone struct {
+	// TTL for records that don't set their own
+	TTL     uint32   `yaml:"ttl"`
+	Records []Record `yaml:"records"`
+
+	records map[recordKey]*Record
+	names   map[string]bool
+}
+
+// Load and validate a zone file
+func Load(path string) (*Zone, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read zone file: %w", err)
+	}
+	return Parse(b)
+}
+
+// Parse and validate zone YAML
+func Parse(b []byte) (*Zone, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	var z Zone
+	if err := decoder.Decode(&z); err != nil {
+		return nil, fmt.Errorf("could not parse zone: %w", err)
+	}
+	if len(z.Records) == 0 {
+		return nil, errors.New("no records found")
+	}
+	if z.TTL == 0 {
+		z.TTL = defaultTTL
+	}
+
+	z.records = make(map[recordKey]*Record)
+	z.names = make(map[string]bool)
+	for i := range z.Records {
+		rec := &z.Records[i]
+		if err := rec.parse(z.TTL); err != nil {
+			return nil, fmt.Errorf("record %d (%s %s): %w", i, rec.Name, rec.Type, err)
+		}
+		key := recordKey{name: rec.Name, rtype: rec.rtype}
+		if _, exists := z.records[key]; exists {
+			return nil, fmt.Errorf("record %d (%s %s): duplicate record", i, rec.Name, rec.Type)
+		}
+		z.records[key] = rec
+		z.names[rec.Name] = true
+	}
+	return &z, nil
+}
+
+func (rec *Record) parse(zoneTTL uint32) error {
+	if rec.Name == "" {
+		return errors.New("name is required")
+	}
+	rec.Name = strings.ToLower(dns.Fqdn(rec.Name))
+	rtype, exists := dns.StringToType[strings.ToUpper(rec.Type)]
+	if !exists {
+		return fmt.Errorf("unknown type: %s", rec.Type)
+	}
+	rec.Type = strings.ToUpper(rec.Type)
+	rec.rtype = rtype
+	if rec.TTL == 0 {
+		rec.TTL = zoneTTL
+	}
+	if len(rec.Rules) == 0 {
+		return errors.New("at least one rule is required")
+	}
+
+	for i := range rec.Rules {
+		rule := &rec.Rules[i]
+		if err := rule.parse(rec); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (rule *Rule) parse(rec *Record) error {
+	for _, s := range rule.Subnets {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return fmt.Errorf("invalid subnet: %w", err)
+		}
+		rule.subnets = append(rule.subnets, prefix.Masked())
+	}
+
+	rule.rcode = dns.RcodeSuccess
+	if rule.Rcode != "" {
+		rcode, exists := dns.StringToRcode[strings.ToUpper(rule.Rcode)]
+		if !exists {
+			return fmt.Errorf("unknown rcode: %s", rule.Rcode)
+		}
+		rule.rcode = rcode
+	}
+	if rule.rcode == dns.RcodeSuccess && len(rule.Answers) == 0 {
+		return errors.New("answers or a non-NOERROR rcode is required")
+	}
+
+	for _, rate := range []float64{rule.ServfailRate, rule.TimeoutRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("rates must be between 0 and 1: %v", rate)
+		}
+	}
+	if rule.ServfailRate+rule.TimeoutRate > 1 {
+		return errors.New("servfail_rate and timeout_rate must add up to at most 1")
+	}
+	if rule.Latency < 0 {
+		return errors.New("latency can't be negative")
+	}
+
+	weighted := 0
+	for i := range rule.Answers {
+		a := &rule.Answers[i]
+		if a.Weight < 0 {
+			return fmt.Errorf("answer weight can't be negative: %s", a.Value)
+		}
+		if a.Weight > 0 {
+			weighted++
+		}
+		rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", rec.Name, rec.TTL, rec.Type, a.Value))
+		if err != nil {
+			return fmt.Errorf("invalid answer %q: %w", a.Value, err)
+		}
+		if rr == nil {
+			return fmt.Errorf("invalid answer %q", a.Value)
+		}
+		a.rr = rr
+	}
+	if weighted > 0 && weighted != len(rule.Answers) {
+		return errors.New("either every answer or no answer must have a weight")
+	}
+	rule.weighted = weighted > 0
+	return nil
+}
+
+func (rule *Rule) matches(client netip.Addr) bool {
+	if len(rule.subnets) == 0 {
+		return true
+	}
+	if !client.IsValid() {
+		return false
+	}
+	for _, s := range rule.subnets {
+		if s.Contains(client) {
+			return true
+		}
+	}
+	return false
+}